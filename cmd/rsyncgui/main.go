@@ -1,12 +1,14 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"net"
 	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
 	"time"
 
@@ -18,12 +20,26 @@ func main() {
 	// ====== 1. 定义启动参数 ======
 	var (
 		configPath string
+		tasksPath  string
+		schedPath  string
+		notifyPath string
 		listenAddr string
+		dataDir    string
+		maxJobs    int
 		openUI     bool
+		retention  app.RetentionPolicy
 	)
 
 	flag.StringVar(&configPath, "config", "", "path to hosts yaml (default: env RSYNCGUI_HOSTS or ./hosts.yaml)")
+	flag.StringVar(&tasksPath, "tasks", "", "path to saved tasks yaml (default: env RSYNCGUI_TASKS or tasks.yaml next to the hosts yaml)")
+	flag.StringVar(&schedPath, "schedules", "", "path to schedules yaml (default: env RSYNCGUI_SCHEDULES or schedules.yaml next to the tasks yaml)")
+	flag.StringVar(&notifyPath, "notify", "", "path to notification yaml (default: env RSYNCGUI_NOTIFY or notify.yaml next to the hosts yaml)")
 	flag.StringVar(&listenAddr, "addr", "", "listen address (default: env RSYNCGUI_ADDR or 127.0.0.1:0)")
+	flag.StringVar(&dataDir, "data", "", "data directory for job history (default: env RSYNCGUI_DATA or ./data)")
+	flag.IntVar(&maxJobs, "max-jobs", 4, "max concurrently running jobs (0 = unlimited)")
+	flag.DurationVar(&retention.MaxAge, "keep-for", 0, "delete finished jobs, their logs and unused staging caches after this long (0 = keep forever)")
+	flag.DurationVar(&retention.FailedMaxAge, "keep-failed-for", 0, "keep failed jobs this long instead (0 = same as -keep-for)")
+	flag.IntVar(&retention.MaxJobs, "keep-jobs", 0, "max finished jobs to keep (0 = unlimited); recent failed jobs are exempt")
 	flag.BoolVar(&openUI, "open", true, "open browser on start")
 	flag.Parse()
 
@@ -35,6 +51,27 @@ func main() {
 		configPath = "hosts.yaml"
 	}
 
+	if tasksPath == "" {
+		tasksPath = os.Getenv("RSYNCGUI_TASKS")
+	}
+	if tasksPath == "" {
+		tasksPath = filepath.Join(filepath.Dir(configPath), "tasks.yaml")
+	}
+
+	if schedPath == "" {
+		schedPath = os.Getenv("RSYNCGUI_SCHEDULES")
+	}
+	if schedPath == "" {
+		schedPath = filepath.Join(filepath.Dir(tasksPath), "schedules.yaml")
+	}
+
+	if notifyPath == "" {
+		notifyPath = os.Getenv("RSYNCGUI_NOTIFY")
+	}
+	if notifyPath == "" {
+		notifyPath = filepath.Join(filepath.Dir(configPath), "notify.yaml")
+	}
+
 	if listenAddr == "" {
 		listenAddr = os.Getenv("RSYNCGUI_ADDR")
 	}
@@ -42,6 +79,13 @@ func main() {
 		listenAddr = "127.0.0.1:0"
 	}
 
+	if dataDir == "" {
+		dataDir = os.Getenv("RSYNCGUI_DATA")
+	}
+	if dataDir == "" {
+		dataDir = "data"
+	}
+
 	// ====== 3. 加载 hosts.yaml ======
 	hostConfigs, err := app.LoadHosts(configPath)
 	if err != nil {
@@ -49,11 +93,23 @@ func main() {
 	}
 
 	// ====== 4. 初始化核心 App ======
-	coreApp, err := app.NewApp(hostConfigs)
+	coreApp, err := app.NewApp(hostConfigs, app.AppOptions{
+		DataDir:       dataDir,
+		MaxConcurrent: maxJobs,
+		TasksPath:     tasksPath,
+		SchedulesPath: schedPath,
+		NotifyPath:    notifyPath,
+		Retention:     retention,
+	})
 	if err != nil {
 		log.Fatalf("init app failed: %v", err)
 	}
 
+	// 定时任务
+	go coreApp.Schedules.Run(context.Background())
+	// 历史清理
+	go coreApp.JobManager.RunPruner(context.Background())
+
 	// ====== 5. API Server ======
 	apiHandler := httpapi.NewServer(coreApp)
 
@@ -65,8 +121,8 @@ func main() {
 
 	url := listenURL(ln.Addr())
 	log.Printf(
-		"rsync-gui listening on %s (config=%s)",
-		url, configPath,
+		"rsync-gui listening on %s (config=%s, tasks=%s, schedules=%s, data=%s)",
+		url, configPath, tasksPath, schedPath, dataDir,
 	)
 
 	if openUI {
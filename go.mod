@@ -5,6 +5,7 @@ go 1.25.0
 require (
 	github.com/gokrazy/rsync v0.2.10
 	github.com/google/uuid v1.6.0
+	github.com/robfig/cron/v3 v3.0.1
 	golang.org/x/crypto v0.46.0
 	gopkg.in/yaml.v3 v3.0.1
 )
@@ -6,17 +6,18 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net"
 	"os"
-	"os/exec"
+	"path"
 	"path/filepath"
-	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/gokrazy/rsync/rsyncclient"
+	"github.com/gokrazy/rsync/rsyncd"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/agent"
@@ -25,29 +26,136 @@ import (
 type JobManager struct {
 	mu    sync.RWMutex
 	jobs  map[string]*Job
+	order []*Job // 按 CreatedAt 升序，见 jobquery.go
 	hosts *HostRegistry
+	store JobStore // 可为 nil：只存内存
+
+	logDir      string // 完整日志目录，为空则只在内存里保留最近的行
+	logMemLines int
+	stagingDir  string // two_step_local 的本机中转目录，见 relay.go
+
+	finishHooks []func(*Job)    // 任务进入终态后调用，见 OnJobFinished
+	retention   RetentionPolicy // 历史保留策略，见 retention.go
+
+	// 清理历史时顺带清理流水线记录，见 pipeline.go
+	prunePipelines func(now time.Time, p RetentionPolicy, dryRun bool) []string
+
+	// 调度：见 queue.go
+	queueMu     sync.Mutex
+	queue       []*queuedJob
+	maxRunning  int // 0 = 不限制
+	running     int
+	hostRunning map[string]int
+	runFn       func(ctx context.Context, job *Job) // 拿到槽位后执行任务，nil = runJob；测试里换成可控的 runner
 }
 
-func NewJobManager(hosts *HostRegistry) *JobManager {
-	return &JobManager{
-		jobs:  make(map[string]*Job),
-		hosts: hosts,
+// JobManagerOptions：NewJobManager 的可选配置
+type JobManagerOptions struct {
+	Store         JobStore // 为 nil 则只存内存
+	MaxConcurrent int      // 全局同时运行的任务数上限，0 = 不限制
+	LogDir        string   // 每个任务的完整日志写到 LogDir/<id>.log
+	LogMemLines   int      // 每个任务在内存里保留的日志行数，0 = 默认 2000
+	Retention     RetentionPolicy
+	StagingDir    string // 经本机中转时的 staging 目录，为空则用系统临时目录
+}
+
+// NewJobManager：Store 非 nil 时从中加载历史，上次进程退出时还在跑的任务标记为 interrupted
+func NewJobManager(hosts *HostRegistry, opts JobManagerOptions) (*JobManager, error) {
+	m := &JobManager{
+		jobs:        make(map[string]*Job),
+		hosts:       hosts,
+		store:       opts.Store,
+		maxRunning:  opts.MaxConcurrent,
+		hostRunning: make(map[string]int),
+		logDir:      opts.LogDir,
+		logMemLines: opts.LogMemLines,
+		retention:   opts.Retention,
+		stagingDir:  opts.StagingDir,
+	}
+	if m.store == nil {
+		return m, nil
+	}
+
+	history, err := m.store.LoadAll()
+	if err != nil {
+		return nil, fmt.Errorf("load job history: %w", err)
+	}
+	for _, job := range history {
+		job.logSink.max = m.logMemLines
+		if job.LogTotal < len(job.LogLines) {
+			job.LogTotal = len(job.LogLines) // 旧记录没有 LogTotal
+		}
+		if path := jobLogPath(m.logDir, job.ID); path != "" {
+			if _, err := os.Stat(path); err == nil {
+				job.logSink.path = path
+			}
+		}
+		if job.Status == JobPending || job.Status == JobRunning {
+			if job.FanOutResult != nil {
+				job.FanOutResult.interrupt()
+			}
+			job.appendLogLocked("interrupted: rsyncgui exited while the job was " + string(job.Status))
+			job.finishLocked(JobInterrupted)
+			m.persist(job)
+		}
+		m.jobs[job.ID] = job
+		m.insertOrderLocked(job)
+	}
+	return m, nil
+}
+
+// OnJobFinished 注册任务结束回调（success/failed/cancelled）；回调里不要做耗时操作
+func (m *JobManager) OnJobFinished(fn func(*Job)) {
+	m.mu.Lock()
+	m.finishHooks = append(m.finishHooks, fn)
+	m.mu.Unlock()
+}
+
+func (m *JobManager) jobFinished(job *Job) {
+	m.mu.RLock()
+	hooks := m.finishHooks
+	m.mu.RUnlock()
+	for _, fn := range hooks {
+		fn(job)
+	}
+}
+
+// persist 把 job 快照写入 store；失败只打日志，不影响任务本身
+func (m *JobManager) persist(job *Job) {
+	if m.store == nil {
+		return
+	}
+	if err := m.store.Save(job); err != nil {
+		log.Printf("[jobstore] save job %s: %v", job.ID, err)
 	}
 }
 
 func (m *JobManager) NewJob(req TransferRequest, plan *TransferPlan) *Job {
+	return m.newJob(req, plan, SubmitOptions{})
+}
+
+func (m *JobManager) newJob(req TransferRequest, plan *TransferPlan, opts SubmitOptions) *Job {
 	id := uuid.New().String()
 	job := &Job{
 		ID:        id,
+		ParentID:  opts.ParentID,
+		Task:      opts.Task,
+		Schedule:  opts.Schedule,
+		Pipeline:  opts.Pipeline,
+		FanOut:    opts.FanOut,
 		Request:   req,
 		Plan:      *plan,
 		Status:    JobPending,
 		CreatedAt: time.Now(),
 		LogLines:  make([]string, 0, 128),
+		Progress:  Progress{ETASeconds: -1},
+		logSink:   openJobLog(m.logDir, id, m.logMemLines),
 	}
 	m.mu.Lock()
 	m.jobs[id] = job
+	m.insertOrderLocked(job)
 	m.mu.Unlock()
+	m.persist(job)
 	return job
 }
 
@@ -58,55 +166,226 @@ func (m *JobManager) GetJob(id string) (*Job, bool) {
 	return j, ok
 }
 
+// ListJobs：全部任务，最新的排前面
 func (m *JobManager) ListJobs() []*Job {
 	m.mu.RLock()
-	out := make([]*Job, 0, len(m.jobs))
-	for _, j := range m.jobs {
-		out = append(out, j)
+	defer m.mu.RUnlock()
+	out := make([]*Job, 0, len(m.order))
+	for i := len(m.order) - 1; i >= 0; i-- {
+		out = append(out, m.order[i])
 	}
-	m.mu.RUnlock()
-
-	// 最新的排前面；如果 CreatedAt 相同，用 ID 稳定一下
-	sort.Slice(out, func(i, j int) bool {
-		if out[i].CreatedAt.Equal(out[j].CreatedAt) {
-			return out[i].ID > out[j].ID
-		}
-		return out[i].CreatedAt.After(out[j].CreatedAt)
-	})
 	return out
 }
 
-func (m *JobManager) StartJob(job *Job) { go m.runJob(job) }
+var (
+	ErrJobNotFound  = errors.New("job not found")
+	ErrJobNotActive = errors.New("job is not pending or running")
+)
 
-func (m *JobManager) runJob(job *Job) {
+// StartJob 把任务放进调度队列；拿到全局和各主机的槽位后才真正开始（之前一直是 JobPending）
+func (m *JobManager) StartJob(job *Job) {
+	// ctx 在这里同步创建，保证 StartJob 返回后 CancelJob 一定能取消到它
+	ctx, cancel := context.WithCancel(context.Background())
 	job.mu.Lock()
-	job.Status = JobRunning
+	job.cancelFn = cancel
+	job.appendLogLocked(fmt.Sprintf("queued (priority=%d)", job.Request.Priority))
+	job.mu.Unlock()
+
+	m.enqueue(&queuedJob{
+		job:   job,
+		ctx:   ctx,
+		hosts: planSlotHosts(&job.Plan),
+	})
+}
+
+// startUnqueued：不经过队列、不占执行槽位直接开始（扇出父任务），run 返回终态
+func (m *JobManager) startUnqueued(job *Job, run func(ctx context.Context) JobStatus) {
+	ctx, cancel := context.WithCancel(context.Background())
+	job.mu.Lock()
+	job.cancelFn = cancel
+	job.setStatusLocked(JobRunning)
 	job.StartedAt = time.Now()
 	job.mu.Unlock()
+	m.persist(job)
 
-	ctx := context.Background()
+	go func() {
+		defer cancel()
+		status := run(ctx)
+		if ctx.Err() != nil && status == JobOK {
+			status = JobCancel
+		}
+		job.mu.Lock()
+		job.finishLocked(status)
+		job.mu.Unlock()
+		m.persist(job)
+		m.jobFinished(job)
+	}()
+}
+
+// CancelJob 取消一个排队中/运行中的任务；ctx 会一路传到 rsyncclient、SSH session 和本机子进程
+func (m *JobManager) CancelJob(id string) (*Job, error) {
+	job, ok := m.GetJob(id)
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+
+	job.mu.Lock()
+	if job.Status != JobPending && job.Status != JobRunning {
+		status := job.Status
+		job.mu.Unlock()
+		return job, fmt.Errorf("%w (status=%s)", ErrJobNotActive, status)
+	}
+	job.appendLogLocked("cancel requested")
+	cancel := job.cancelFn
+	job.mu.Unlock()
 
+	// 还在队列里的任务不会再被调度，直接结束；已经被调度走的交给 runJob 通过 ctx 收尾
+	if cancel != nil && !m.dequeue(job) {
+		cancel()
+		return job, nil
+	}
+	if cancel != nil {
+		cancel()
+	}
+	job.mu.Lock()
+	job.finishLocked(JobCancel)
+	job.mu.Unlock()
+	m.persist(job)
+	m.jobFinished(job)
+	return job, nil
+}
+
+func (m *JobManager) runJob(ctx context.Context, job *Job) {
+	job.mu.Lock()
+	cancel := job.cancelFn
+	if ctx.Err() != nil {
+		job.finishLocked(JobCancel)
+		job.mu.Unlock()
+		m.persist(job)
+		m.jobFinished(job)
+		return
+	}
+	job.setStatusLocked(JobRunning)
+	job.StartedAt = time.Now()
+	job.mu.Unlock()
+	m.persist(job)
+	defer cancel()
+	defer m.jobFinished(job)
+	defer m.persist(job)
+
+	if job.Plan.Reason != "" {
+		job.appendLog("[plan] " + job.Plan.Reason)
+	}
+	for _, h := range job.Plan.Hops {
+		job.appendLog(describeHop(h))
+	}
 	runner, err := m.buildRunner(job)
 	if err != nil {
 		job.mu.Lock()
-		job.Status = JobFailed
-		job.LogLines = append(job.LogLines, "build runner failed: "+err.Error())
-		job.EndedAt = time.Now()
+		job.appendLogLocked("build runner failed: " + err.Error())
+		job.finishLocked(JobFailed)
 		job.mu.Unlock()
 		return
 	}
 
-	err = runner(ctx)
+	if hook := job.Request.PreCommand; hook.enabled() {
+		if err := m.runHook(ctx, job, "pre", hook); err != nil {
+			cancelled := ctx.Err() != nil
+			if cancelled {
+				job.appendLog("transfer cancelled during pre-hook")
+			} else {
+				job.appendLog("pre-hook failed, transfer aborted: " + err.Error())
+			}
+			// when=always 的 post hook 这时也要跑（比如把 pre hook 停掉的服务拉起来）
+			if hookErr := m.runPostHook(ctx, job, fmt.Errorf("pre-hook: %w", err)); hookErr != nil {
+				job.appendLog("[post-hook] failed: " + hookErr.Error())
+			}
+			job.mu.Lock()
+			if cancelled {
+				job.finishLocked(JobCancel)
+			} else {
+				job.finishLocked(JobFailed)
+			}
+			job.mu.Unlock()
+			return
+		}
+	}
+
+	err = m.runAttempts(ctx, job, runner)
+	if err == nil && ctx.Err() == nil {
+		job.finishProgress()
+	}
+	if hookErr := m.runPostHook(ctx, job, err); hookErr != nil {
+		job.appendLog("[post-hook] failed: " + hookErr.Error())
+		if err == nil {
+			err = fmt.Errorf("post-hook: %w", hookErr)
+		}
+	}
 
 	job.mu.Lock()
 	defer job.mu.Unlock()
-	if err != nil {
-		job.Status = JobFailed
-		job.LogLines = append(job.LogLines, "transfer failed: "+err.Error())
-	} else {
-		job.Status = JobOK
+	switch {
+	case ctx.Err() != nil:
+		if err != nil {
+			job.appendLogLocked("transfer cancelled: " + err.Error())
+		} else {
+			job.appendLogLocked("transfer cancelled")
+		}
+		job.finishLocked(JobCancel)
+	case err != nil:
+		job.appendLogLocked("transfer failed: " + err.Error())
+		job.finishLocked(JobFailed)
+	default:
+		job.finishLocked(JobOK)
+	}
+}
+
+// runAttempts 按 Request.Retry 执行 runner：临时错误退避重试，每次尝试单独记进 Attempts 和日志
+func (m *JobManager) runAttempts(ctx context.Context, job *Job, runner func(context.Context) error) error {
+	policy := job.Request.Retry
+	total := policy.attempts()
+
+	for attempt := 1; ; attempt++ {
+		if total > 1 {
+			job.appendLog(fmt.Sprintf("=== attempt %d/%d ===", attempt, total))
+		}
+
+		job.resetProgress()
+		job.setStats(nil)
+		rec := JobAttempt{Attempt: attempt, StartedAt: time.Now()}
+		err := runner(ctx)
+		rec.EndedAt = time.Now()
+		if err != nil {
+			rec.Error = err.Error()
+			rec.Transient = ctx.Err() == nil && isTransientError(err)
+		}
+
+		job.mu.Lock()
+		job.Attempts = append(job.Attempts, rec)
+		job.mu.Unlock()
+
+		if err != nil && ctx.Err() == nil && m.innerHopsToWan(job, err) {
+			// LAN 地址连不上导致的失败，换 WAN 再跑一次，不占重试次数
+			job.appendLog("[net] exec host could not reach its peer over LAN, retrying on WAN")
+			total++
+			continue
+		}
+		if err == nil || ctx.Err() != nil || attempt >= total {
+			return err
+		}
+		if !rec.Transient {
+			job.appendLog("[retry] permanent error, not retrying: " + err.Error())
+			return err
+		}
+
+		wait := policy.backoff(attempt)
+		job.appendLog(fmt.Sprintf("[retry] attempt %d failed with transient error: %v; retrying in %s", attempt, err, wait))
+		m.persist(job)
+
+		if sleepErr := sleepCtx(ctx, wait); sleepErr != nil {
+			return err
+		}
 	}
-	job.EndedAt = time.Now()
 }
 
 type DialTarget struct {
@@ -114,10 +393,6 @@ type DialTarget struct {
 	Port int
 }
 
-func decideUseLan(req TransferRequest) bool {
-	return strings.EqualFold(req.Options.Profile, "LAN")
-}
-
 func dialFor(c *HostConfig, useLan bool) DialTarget {
 	if useLan && c.LanHost != "" && c.LanPort > 0 {
 		return DialTarget{Host: c.LanHost, Port: c.LanPort}
@@ -153,17 +428,36 @@ func (m *JobManager) buildRunner(job *Job) (func(context.Context) error, error)
 			}, nil
 		case srcHost == "local" && dstHost != "local":
 			return func(ctx context.Context) error {
-				return m.runLocalToRemote_GoRsync(job, ctx)
+				return m.runLocalToRemote_GoRsync(job, ctx, plan.Source, plan.Dest, &job.Request.Options)
 			}, nil
 		case srcHost != "local" && dstHost == "local":
 			return func(ctx context.Context) error {
-				return m.runRemoteToLocal_GoRsync(job, ctx)
+				return m.runRemoteToLocal_GoRsync(job, ctx, plan.Source, plan.Dest, &job.Request.Options)
 			}, nil
 		default:
 			return nil, fmt.Errorf("ExecLocal unexpected: %s -> %s", srcHost, dstHost)
 		}
 	}
 
+	// 远程↔远程，两边互相连不通：经本机中转
+	if plan.Mode == ExecTwoStepLocal {
+		return func(ctx context.Context) error {
+			return m.runTwoStepLocal(job, ctx)
+		}, nil
+	}
+
+	if plan.Mode == ExecRelayStream {
+		return func(ctx context.Context) error {
+			return m.runRemoteToRemote_RelayStream(job, ctx)
+		}, nil
+	}
+
+	if plan.Mode == ExecViaHost {
+		return func(ctx context.Context) error {
+			return m.runRemoteToRemote_ViaHost(job, ctx)
+		}, nil
+	}
+
 	// 远程↔远程：在某台远程上跑命令行 rsync（第一跳用 Go SSH，带“内置 agent 转发”免 ssh-add）
 	if plan.Mode == ExecOnSource || plan.Mode == ExecOnDest {
 		return func(ctx context.Context) error {
@@ -175,9 +469,10 @@ func (m *JobManager) buildRunner(job *Job) (func(context.Context) error, error)
 }
 
 type jobLineWriter struct {
-	job *Job
-	mu  sync.Mutex
-	buf bytes.Buffer
+	job    *Job
+	prefix string // 每行前面加的标记，可为空
+	mu     sync.Mutex
+	buf    bytes.Buffer
 }
 
 func (w *jobLineWriter) Write(p []byte) (int, error) {
@@ -194,9 +489,7 @@ func (w *jobLineWriter) Write(p []byte) (int, error) {
 		}
 		line := strings.TrimRight(string(b[:i]), "\r")
 		w.buf.Next(i + 1)
-		w.job.mu.Lock()
-		w.job.LogLines = append(w.job.LogLines, line)
-		w.job.mu.Unlock()
+		w.job.appendLog(w.prefix + line)
 	}
 	return n, nil
 }
@@ -209,9 +502,7 @@ func (w *jobLineWriter) Flush() {
 	}
 	line := strings.TrimRight(w.buf.String(), "\r\n")
 	w.buf.Reset()
-	w.job.mu.Lock()
-	w.job.LogLines = append(w.job.LogLines, line)
-	w.job.mu.Unlock()
+	w.job.appendLog(w.prefix + line)
 
 }
 
@@ -382,16 +673,17 @@ func buildGoNativeRsyncArgs(opts *RsyncOptions, w *jobLineWriter) []string {
 
 // PreviewCommand 生成“如果执行该任务，等效的 rsync 命令是什么”
 func (m *JobManager) PreviewCommand(req TransferRequest, plan *TransferPlan) (string, error) {
-	useLan := decideUseLan(req)
-
+	if plan.Mode == ExecFanOut {
+		return fanOutPreview(req, plan), nil
+	}
 	srcHostName := plan.Source.HostName
 	dstHostName := plan.Dest.HostName
 
-	// 1. 本机 <-> 本机
+	// 1. 本机 <-> 本机 (Go Native，进程内 sender/receiver)
 	if plan.Mode == ExecLocal && srcHostName == "local" && dstHostName == "local" {
-		args := buildRsyncArgs(&req.Options)
-		args = append(args, plan.Source.Path, plan.Dest.Path)
-		return "rsync " + joinShellArgs(args), nil
+		args := buildGoNativeRsyncArgs(&req.Options, nil)
+		args = append(append(args, plan.Source.sourcePaths()...), plan.Dest.Path)
+		return "# (Go-native in-process copy, equivalent to:)\nrsync " + joinShellArgs(args), nil
 	}
 
 	// 2. 本机 -> 远程 (Go Native, 模拟显示 rsync 命令)
@@ -400,10 +692,11 @@ func (m *JobManager) PreviewCommand(req TransferRequest, plan *TransferPlan) (st
 		if err != nil {
 			return "", err
 		}
-		d := dialFor(&remoteHost.Config, useLan)
+		d, useLan := plan.dial("local", remoteHost)
 
 		// rsync [args] src user@host:dst
 		args := buildRsyncArgs(&req.Options)
+		args = appendResumeArgs(args, &req.Retry)
 
 		sshCmd := "ssh"
 		if d.Port != 22 {
@@ -418,7 +711,7 @@ func (m *JobManager) PreviewCommand(req TransferRequest, plan *TransferPlan) (st
 		}
 
 		dstSpec := fmt.Sprintf("%s@%s:%s", remoteHost.Config.User, d.Host, plan.Dest.Path)
-		args = append(args, plan.Source.Path, dstSpec)
+		args = append(append(args, plan.Source.sourcePaths()...), dstSpec)
 
 		return "# (Go-native transfer, equivalent to:)\nrsync " + joinShellArgs(args), nil
 	}
@@ -429,7 +722,7 @@ func (m *JobManager) PreviewCommand(req TransferRequest, plan *TransferPlan) (st
 		if err != nil {
 			return "", err
 		}
-		d := dialFor(&remoteHost.Config, useLan)
+		d, useLan := plan.dial("local", remoteHost)
 
 		args := buildRsyncArgs(&req.Options)
 
@@ -445,13 +738,85 @@ func (m *JobManager) PreviewCommand(req TransferRequest, plan *TransferPlan) (st
 			args = append(args, "-e", sshCmd)
 		}
 
-		srcSpec := fmt.Sprintf("%s@%s:%s", remoteHost.Config.User, d.Host, plan.Source.Path)
-		args = append(args, srcSpec, plan.Dest.Path)
+		for _, p := range plan.Source.sourcePaths() {
+			args = append(args, fmt.Sprintf("%s@%s:%s", remoteHost.Config.User, d.Host, p))
+		}
+		args = append(args, plan.Dest.Path)
 
 		return "# (Go-native transfer, equivalent to:)\nrsync " + joinShellArgs(args), nil
 	}
 
-	// 4. 远程 <-> 远程 (OneHopSSH)
+	// 4. 远程 -> 本机 -> 远程 (two_step_local)
+	if plan.Mode == ExecTwoStepLocal {
+		srcHost, err := m.getHost(srcHostName)
+		if err != nil {
+			return "", err
+		}
+		dstHost, err := m.getHost(dstHostName)
+		if err != nil {
+			return "", err
+		}
+		srcDial, _ := plan.dial("local", srcHost)
+		dstDial, _ := plan.dial("local", dstHost)
+
+		pullOpts := req.Options
+		pullOpts.Delete = true
+		pull := buildRsyncArgs(&pullOpts)
+		push := buildRsyncArgs(&req.Options)
+		push = appendResumeArgs(push, &req.Retry)
+
+		stage := "<staging>/"
+		if len(plan.Source.Files) == 0 && !pathHasTrailingSeparator(plan.Source.Path) {
+			stage = "<staging>/" + path.Base(plan.Source.Path)
+		}
+		for _, p := range plan.Source.sourcePaths() {
+			pull = append(pull, fmt.Sprintf("%s@%s:%s", srcHost.Config.User, srcDial.Host, p))
+		}
+		pull = append(pull, "<staging>/")
+		push = append(push, stage, fmt.Sprintf("%s@%s:%s", dstHost.Config.User, dstDial.Host, plan.Dest.Path))
+
+		return "# (Go-native relay through this machine, equivalent to:)\n" +
+			"# step 1/2\nrsync " + joinShellArgs(pull) + "\n" +
+			"# step 2/2\nrsync " + joinShellArgs(push), nil
+	}
+
+	// 5. 远程 -> 本机内存 -> 远程 (relay_stream)
+	if plan.Mode == ExecRelayStream {
+		sa, err := relayStreamArgs(&req.Options, &req.Retry)
+		if err != nil {
+			return "", err
+		}
+		srcArgs, dstArgs := relayServerCommands(plan, sa, "<random>")
+		out := "# (relayed by this machine, nothing written locally)\n" +
+			fmt.Sprintf("# on %s:\nrsync %s\n", srcHostName, joinShellArgs(srcArgs)) +
+			fmt.Sprintf("# on %s:\nrsync %s", dstHostName, joinShellArgs(dstArgs))
+		if len(sa.Filters) > 0 {
+			out += "\n# filter rules sent over the protocol: " + strings.Join(sa.Filters, " | ")
+		}
+		return out, nil
+	}
+
+	// 6. 远程 -> 第三台主机 -> 远程 (via_host)
+	if plan.Mode == ExecViaHost {
+		srcHost, err := m.getHost(srcHostName)
+		if err != nil {
+			return "", err
+		}
+		dstHost, err := m.getHost(dstHostName)
+		if err != nil {
+			return "", err
+		}
+		execHost, err := m.getHost(plan.ExecHost)
+		if err != nil {
+			return "", err
+		}
+		pull, push := viaHostCommands(&req, plan, srcHost, dstHost, "<staging>", previewProgressArgs(execHost))
+		return fmt.Sprintf("# Run on host: %s (relay through its staging dir)\n", plan.ExecHost) +
+			"# step 1/2\n" + pull + "\n" +
+			"# step 2/2\n" + push, nil
+	}
+
+	// 7. 远程 <-> 远程 (OneHopSSH)
 	if plan.Mode == ExecOnSource || plan.Mode == ExecOnDest {
 		execHost, err := m.getHost(plan.ExecHost)
 		if err != nil {
@@ -471,24 +836,28 @@ func (m *JobManager) PreviewCommand(req TransferRequest, plan *TransferPlan) (st
 		}
 
 		// 组 inner ssh
-		innerDial := dialFor(&innerTarget.Config, useLan)
-		innerSSH := buildInnerSSHCommand(innerTarget.Config, innerDial, useLan)
+		innerDial, innerLan := plan.dial(execHost.Config.Name, innerTarget)
+		innerSSH := buildInnerSSHCommand(innerTarget.Config, innerDial, innerLan)
 
 		args := buildRsyncArgs(&req.Options)
+		args = appendResumeArgs(args, &req.Retry)
 		args = append(args, "--protect-args", "-e", innerSSH)
 
+		srcPath, selArgs, filesFrom := selectionCLI(&req.Options, plan.Source)
+		args = append(args, selArgs...)
+
 		var srcSpec, dstSpec string
 		if execIsSource {
 			// execHost=source：src 是本地路径；dst 是 user@host:path
-			srcSpec = plan.Source.Path
+			srcSpec = srcPath
 			dstSpec = fmt.Sprintf("%s@%s:%s", innerTarget.Config.User, innerDial.Host, plan.Dest.Path)
 		} else {
 			// execHost=dest：src 是 user@host:path；dst 是本地路径
-			srcSpec = fmt.Sprintf("%s@%s:%s", innerTarget.Config.User, innerDial.Host, plan.Source.Path)
+			srcSpec = fmt.Sprintf("%s@%s:%s", innerTarget.Config.User, innerDial.Host, srcPath)
 			dstSpec = plan.Dest.Path
 		}
 
-		cmdStr := "rsync " + joinShellArgs(args) + " " + shQuote(srcSpec) + " " + shQuote(dstSpec)
+		cmdStr := rsyncCommandLine(args, filesFrom, srcSpec, dstSpec)
 
 		// 还要显示它是跑在哪台机器上的
 		prefix := fmt.Sprintf("# Run on host: %s\n", execHost.Config.Name)
@@ -499,51 +868,111 @@ func (m *JobManager) PreviewCommand(req TransferRequest, plan *TransferPlan) (st
 }
 
 // ============================
-// 1) local <-> local（不依赖外部 rsync：用 rsynccmd 最简单，但你现在没引它也行）
-// 这里先用系统 rsync（你如果要彻底去掉外部依赖，我再给你换 rsynccmd 版）
+// 1) local <-> local：gokrazy 的 receiver（rsyncclient）和 sender（rsyncd 的 --server --sender）
+// 在进程内用两根 pipe 对接，不依赖本机 rsync（Windows 上也能跑），增量和 --delete 语义和远程一样。
+// 客户端当 receiver：--delete 由它自己处理（gokrazy 不把 --delete 传给 server）。
 // ============================
 func (m *JobManager) runLocalLocal(job *Job, ctx context.Context) error {
 	src := job.Plan.Source.Path
 	dst := job.Plan.Dest.Path
-
-	args := buildRsyncArgs(&job.Request.Options)
-	args = append(args, src, dst)
+	opts := &job.Request.Options
 
 	w := &jobLineWriter{job: job}
-	job.mu.Lock()
-	job.LogLines = append(job.LogLines, "rsync "+strings.Join(args, " "))
-	job.mu.Unlock()
 
-	cmd := exec.CommandContext(ctx, "rsync", args...)
-	cmd.Stdout = w
-	cmd.Stderr = w
-	err := cmd.Run()
+	clientArgs := buildGoNativeRsyncArgs(opts, w)
+	if job.Request.Retry.enabled() {
+		w.appendLine("[retry] Go-native receiver keeps no partial files; a retry resumes at file granularity")
+	}
+	// DontRestrict：不要对整个进程开 landlock，本进程还要继续服务其他任务
+	rsClient, err := rsyncclient.New(clientArgs, rsyncclient.WithStderr(w), rsyncclient.DontRestrict())
+	if err != nil {
+		return fmt.Errorf("rsyncclient.New(receiver): %w", err)
+	}
+	// sender 的调试日志每个文件好几行，不要；它的错误会通过协议和返回值报上来
+	srv, err := rsyncd.NewServer(nil, rsyncd.WithStderr(io.Discard))
+	if err != nil {
+		return fmt.Errorf("rsyncd.NewServer: %w", err)
+	}
+	logLocalPathDiagnostics(w, "local source", src)
+	logLocalPathDiagnostics(w, "local destination", dst)
+	job.setProgressTotals(localSelectionTotals(job.Plan.Source))
+
+	// server 端的源路径相对 "/"，先转成绝对路径（保留结尾的 /）
+	var absSrcs []string
+	for _, p := range job.Plan.Source.sourcePaths() {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return fmt.Errorf("resolve source path: %w", err)
+		}
+		if pathHasTrailingSeparator(p) {
+			abs += string(filepath.Separator)
+		}
+		absSrcs = append(absSrcs, abs)
+	}
+	serverArgs := rsClient.ServerCommandOptions(absSrcs[0], absSrcs[1:]...)
+	job.appendLog("[go-rsync] local copy, sender args: " + joinShellArgs(serverArgs))
+
+	// toServer：receiver -> sender（校验和）；toClient：sender -> receiver（文件列表和数据）
+	toServerR, toServerW := io.Pipe()
+	toClientR, toClientW := io.Pipe()
+	stopCancel := context.AfterFunc(ctx, func() {
+		_ = toServerW.CloseWithError(ctx.Err())
+		_ = toClientW.CloseWithError(ctx.Err())
+	})
+	defer stopCancel()
+
+	srvDone := make(chan error, 1)
+	go func() {
+		conn := rsyncd.NewConnection(toServerR, toClientW, "local")
+		err := srv.HandleConnArgs(ctx, conn, nil, serverArgs)
+		// sender 退出后 receiver 不会再收到数据，别让它一直等
+		_ = toClientW.CloseWithError(err)
+		_ = toServerR.CloseWithError(err)
+		srvDone <- err
+	}()
+
+	conn := &struct {
+		io.Reader
+		io.Writer
+	}{Reader: &progressReader{r: toClientR, job: job}, Writer: toServerW}
+
+	res, err := rsClient.Run(ctx, conn, []string{dst})
+	_ = toServerW.Close()
+	_ = toClientR.Close()
+	srvErr := <-srvDone
 	w.Flush()
-	return err
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		if srvErr != nil {
+			return fmt.Errorf("rsyncclient.Run(receiver): %w; sender: %v", err, srvErr)
+		}
+		return fmt.Errorf("rsyncclient.Run(receiver): %w", err)
+	}
+	job.setStats(goRsyncStats(job, res))
+	return nil
 }
 
 // ============================
 // 2) local -> remote：Go 内置 SSH + gokrazy/rsyncclient（本机不需要 rsync/ssh）
 // ============================
-func (m *JobManager) runLocalToRemote_GoRsync(job *Job, ctx context.Context) error {
-	useLan := decideUseLan(job.Request)
-
-	remoteHost, err := m.getHost(job.Plan.Dest.HostName)
+func (m *JobManager) runLocalToRemote_GoRsync(job *Job, ctx context.Context, src, dst Endpoint, opts *RsyncOptions) error {
+	remoteHost, err := m.getHost(dst.HostName)
 	if err != nil {
 		return err
 	}
-	d := dialFor(&remoteHost.Config, useLan)
-
 	w := &jobLineWriter{job: job}
 
-	clientArgs := buildGoNativeRsyncArgs(&job.Request.Options, w)
+	clientArgs := buildGoNativeRsyncArgs(opts, w)
 	rsClient, err := rsyncclient.New(clientArgs, rsyncclient.WithSender(), rsyncclient.WithStderr(w))
 	if err != nil {
 		return fmt.Errorf("rsyncclient.New(sender): %w", err)
 	}
-	logLocalPathDiagnostics(w, "local source", job.Plan.Source.Path)
+	logLocalPathDiagnostics(w, "local source", src.Path)
+	job.setProgressTotals(localSelectionTotals(src))
 
-	sshCli, err := sshDial(&remoteHost.Config, d, useLan)
+	sshCli, d, err := dialHop(ctx, job, remoteHost)
 	if err != nil {
 		return err
 	}
@@ -554,15 +983,17 @@ func (m *JobManager) runLocalToRemote_GoRsync(job *Job, ctx context.Context) err
 		return err
 	}
 	if transport == remoteUploadTransportSCP {
-		return m.runLocalToRemote_SCP(job, ctx, sshCli, remoteHost, d)
+		return m.runLocalToRemote_SCP(job, ctx, sshCli, remoteHost, d, src, dst.Path, opts)
 	}
-	logRemotePathDiagnostics(ctx, sshCli, "destination before rsync", job.Plan.Dest.Path, w)
+	logRemotePathDiagnostics(ctx, sshCli, "destination before rsync", dst.Path, w)
 
 	sess, err := sshCli.NewSession()
 	if err != nil {
 		return err
 	}
 	defer sess.Close()
+	stopCancel := closeSessionOnCancel(ctx, sess)
+	defer stopCancel()
 
 	stdin, err := sess.StdinPipe()
 	if err != nil {
@@ -574,18 +1005,21 @@ func (m *JobManager) runLocalToRemote_GoRsync(job *Job, ctx context.Context) err
 	}
 	sess.Stderr = w
 
-	remoteServerArgs := forceRemoteRsyncProtocol(rsClient.ServerCommandOptions(job.Plan.Dest.Path))
-	remoteCmd := "rsync " + joinShellArgs(remoteServerArgs)
+	remoteServerArgs := forceRemoteRsyncProtocol(rsClient.ServerCommandOptions(dst.Path))
+	// 续传由远端的 rsync receiver 负责，见 serverResumeArgs
+	remoteServerArgs = serverResumeArgs(remoteServerArgs, opts.ExtraArgs, &job.Request.Retry)
+	remoteCmd := "exec rsync " + joinShellArgs(remoteServerArgs)
 
-	job.mu.Lock()
-	job.LogLines = append(job.LogLines, fmt.Sprintf("[go-rsync] ssh %s@%s:%d  %s", remoteHost.Config.User, d.Host, d.Port, remoteCmd))
-	job.mu.Unlock()
+	job.appendLog(fmt.Sprintf("[go-rsync] ssh %s@%s:%d  %s", remoteHost.Config.User, d.Host, d.Port, remoteCmd))
 
 	if err := sess.Start("sh -c " + shQuote(remoteCmd)); err != nil {
 		w.appendLine("[go-rsync] start remote rsync server failed: " + err.Error())
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		w.appendLine("[go-rsync] trying scp fallback after rsync start failure")
 		w.Flush()
-		if fallbackErr := m.runLocalToRemote_SCP(job, ctx, sshCli, remoteHost, d); fallbackErr != nil {
+		if fallbackErr := m.runLocalToRemote_SCP(job, ctx, sshCli, remoteHost, d, src, dst.Path, opts); fallbackErr != nil {
 			return fmt.Errorf("start remote rsync server: %v; scp fallback failed: %w", err, fallbackErr)
 		}
 		return nil
@@ -594,9 +1028,10 @@ func (m *JobManager) runLocalToRemote_GoRsync(job *Job, ctx context.Context) err
 	rw := &struct {
 		io.Reader
 		io.Writer
-	}{Reader: stdout, Writer: stdin}
+	}{Reader: stdout, Writer: &progressWriter{w: stdin, job: job}}
 
-	if _, err := rsClient.Run(ctx, rw, []string{job.Plan.Source.Path}); err != nil {
+	res, err := rsClient.Run(ctx, rw, src.sourcePaths())
+	if err != nil {
 		_ = sess.Close()
 		waitCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 		waitErr := waitSession(waitCtx, sess)
@@ -607,61 +1042,72 @@ func (m *JobManager) runLocalToRemote_GoRsync(job *Job, ctx context.Context) err
 			w.appendLine("[go-rsync] remote rsync server after client error: exited cleanly")
 		}
 		w.appendLine("[go-rsync] local rsyncclient sender error: " + err.Error())
-		logRemotePathDiagnostics(ctx, sshCli, "destination after rsync failure", job.Plan.Dest.Path, w)
+		if ctx.Err() != nil {
+			w.Flush()
+			return ctx.Err()
+		}
+		logRemotePathDiagnostics(ctx, sshCli, "destination after rsync failure", dst.Path, w)
 		w.Flush()
 		w.appendLine("[go-rsync] trying scp fallback after rsync transfer failure")
 		w.Flush()
-		if fallbackErr := m.runLocalToRemote_SCP(job, ctx, sshCli, remoteHost, d); fallbackErr != nil {
+		if fallbackErr := m.runLocalToRemote_SCP(job, ctx, sshCli, remoteHost, d, src, dst.Path, opts); fallbackErr != nil {
 			return fmt.Errorf("rsyncclient.Run(sender): %v; scp fallback failed: %w", err, fallbackErr)
 		}
 		return nil
 	}
 
-	if err := sess.Wait(); err != nil {
+	if err := waitSession(ctx, sess); err != nil {
 		w.appendLine("[go-rsync] remote rsync server exit error: " + err.Error())
-		logRemotePathDiagnostics(ctx, sshCli, "destination after remote rsync exit", job.Plan.Dest.Path, w)
+		if ctx.Err() != nil {
+			w.Flush()
+			return ctx.Err()
+		}
+		logRemotePathDiagnostics(ctx, sshCli, "destination after remote rsync exit", dst.Path, w)
 		w.Flush()
 		w.appendLine("[go-rsync] trying scp fallback after remote rsync exit failure")
 		w.Flush()
-		if fallbackErr := m.runLocalToRemote_SCP(job, ctx, sshCli, remoteHost, d); fallbackErr != nil {
+		if fallbackErr := m.runLocalToRemote_SCP(job, ctx, sshCli, remoteHost, d, src, dst.Path, opts); fallbackErr != nil {
 			return fmt.Errorf("remote rsync server exit: %v; scp fallback failed: %w", err, fallbackErr)
 		}
 		return nil
 	}
+	job.setStats(goRsyncStats(job, res))
 
 	w.Flush()
 	return nil
 }
 
-func (m *JobManager) runLocalToRemote_SCP(job *Job, ctx context.Context, sshCli *ssh.Client, remoteHost *Host, d DialTarget) error {
-	if err := ensureScpFallbackSafe(&job.Request.Options); err != nil {
+func (m *JobManager) runLocalToRemote_SCP(job *Job, ctx context.Context, sshCli *ssh.Client, remoteHost *Host, d DialTarget, srcEp Endpoint, dst string, opts *RsyncOptions) error {
+	if err := ctx.Err(); err != nil {
 		return err
 	}
-
-	dst := job.Plan.Dest.Path
-	src := job.Plan.Source.Path
+	if err := ensureScpFallbackSafe(opts); err != nil {
+		return err
+	}
+	if len(srcEp.Files) > 0 {
+		return fmt.Errorf("remote rsync unavailable; scp fallback does not support a file selection")
+	}
+	src := srcEp.Path
 
 	w := &jobLineWriter{job: job}
-	job.mu.Lock()
-	job.LogLines = append(job.LogLines,
+	job.appendLog(
 		fmt.Sprintf("[go-scp] ssh %s@%s:%d  mkdir -p %s && scp -r -t %s",
 			remoteHost.Config.User, d.Host, d.Port, shQuote(dst), shQuote(dst),
 		),
 	)
-	for _, warning := range scpFallbackWarnings(&job.Request.Options) {
-		job.LogLines = append(job.LogLines, warning)
-	}
-	job.mu.Unlock()
+	job.appendLog(scpFallbackWarnings(opts)...)
+
+	job.resetProgress()
+	job.setProgressTotals(localPathTotals(src))
 
 	sendContents := pathHasTrailingSeparator(src)
-	if job.Request.Options.Compress {
-		job.mu.Lock()
-		job.LogLines = append(job.LogLines, "[go-scp] compression requested; using tar.gz stream over ssh")
-		job.mu.Unlock()
+	if opts.Compress {
+		job.appendLog("[go-scp] compression requested; using tar.gz stream over ssh")
 		if err := tarGzipSendLocalPath(ctx, sshCli, src, dst, sendContents, w); err != nil {
 			w.Flush()
 			return fmt.Errorf("compressed fallback transfer: %w", err)
 		}
+		job.setStats(progressStats(job, "tar"))
 		w.Flush()
 		return nil
 	}
@@ -670,6 +1116,7 @@ func (m *JobManager) runLocalToRemote_SCP(job *Job, ctx context.Context, sshCli
 		w.Flush()
 		return fmt.Errorf("scp fallback transfer: %w", err)
 	}
+	job.setStats(progressStats(job, "scp"))
 
 	w.Flush()
 	return nil
@@ -710,36 +1157,37 @@ func pathHasTrailingSeparator(path string) bool {
 // ============================
 // 3) remote -> local：Go 内置 SSH + gokrazy/rsyncclient（本机不需要 rsync/ssh）
 // ============================
-func (m *JobManager) runRemoteToLocal_GoRsync(job *Job, ctx context.Context) error {
-	useLan := decideUseLan(job.Request)
-
-	remoteHost, err := m.getHost(job.Plan.Source.HostName)
+func (m *JobManager) runRemoteToLocal_GoRsync(job *Job, ctx context.Context, src, dst Endpoint, opts *RsyncOptions) error {
+	remoteHost, err := m.getHost(src.HostName)
 	if err != nil {
 		return err
 	}
-	d := dialFor(&remoteHost.Config, useLan)
-
 	w := &jobLineWriter{job: job}
 
-	clientArgs := buildGoNativeRsyncArgs(&job.Request.Options, w)
+	clientArgs := buildGoNativeRsyncArgs(opts, w)
+	if job.Request.Retry.enabled() {
+		w.appendLine("[retry] Go-native receiver keeps no partial files; a retry resumes at file granularity")
+	}
 	rsClient, err := rsyncclient.New(clientArgs, rsyncclient.WithStderr(w))
 	if err != nil {
 		return fmt.Errorf("rsyncclient.New(receiver): %w", err)
 	}
-	logLocalPathDiagnostics(w, "local destination", job.Plan.Dest.Path)
+	logLocalPathDiagnostics(w, "local destination", dst.Path)
 
-	sshCli, err := sshDial(&remoteHost.Config, d, useLan)
+	sshCli, d, err := dialHop(ctx, job, remoteHost)
 	if err != nil {
 		return err
 	}
 	defer sshCli.Close()
-	logRemotePathDiagnostics(ctx, sshCli, "source before rsync", job.Plan.Source.Path, w)
+	logRemotePathDiagnostics(ctx, sshCli, "source before rsync", src.Path, w)
 
 	sess, err := sshCli.NewSession()
 	if err != nil {
 		return err
 	}
 	defer sess.Close()
+	stopCancel := closeSessionOnCancel(ctx, sess)
+	defer stopCancel()
 
 	stdin, err := sess.StdinPipe()
 	if err != nil {
@@ -751,14 +1199,13 @@ func (m *JobManager) runRemoteToLocal_GoRsync(job *Job, ctx context.Context) err
 	}
 	sess.Stderr = w
 
-	remoteServerArgs := forceRemoteRsyncProtocol(rsClient.ServerCommandOptions(job.Plan.Source.Path))
-	remoteCmd := "cd ~ 2>/dev/null && command rsync " + joinShellArgs(remoteServerArgs)
+	srcPaths := src.sourcePaths()
+	remoteServerArgs := forceRemoteRsyncProtocol(rsClient.ServerCommandOptions(srcPaths[0], srcPaths[1:]...))
+	remoteCmd := "cd ~ 2>/dev/null && exec rsync " + joinShellArgs(remoteServerArgs)
 
-	job.mu.Lock()
-	job.LogLines = append(job.LogLines,
+	job.appendLog(
 		fmt.Sprintf("[go-rsync] ssh %s@%s:%d  %s", remoteHost.Config.User, d.Host, d.Port, remoteCmd),
 	)
-	job.mu.Unlock()
 
 	if err := sess.Start("sh -c " + shQuote(remoteCmd)); err != nil {
 		return fmt.Errorf("start remote rsync server: %w", err)
@@ -767,9 +1214,10 @@ func (m *JobManager) runRemoteToLocal_GoRsync(job *Job, ctx context.Context) err
 	rw := &struct {
 		io.Reader
 		io.Writer
-	}{Reader: stdout, Writer: stdin}
+	}{Reader: &progressReader{r: stdout, job: job}, Writer: stdin}
 
-	if _, err := rsClient.Run(ctx, rw, []string{job.Plan.Dest.Path}); err != nil {
+	res, err := rsClient.Run(ctx, rw, []string{dst.Path})
+	if err != nil {
 		_ = sess.Close()
 		waitCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 		waitErr := waitSession(waitCtx, sess)
@@ -780,17 +1228,30 @@ func (m *JobManager) runRemoteToLocal_GoRsync(job *Job, ctx context.Context) err
 			w.appendLine("[go-rsync] remote rsync server after client error: exited cleanly")
 		}
 		w.appendLine("[go-rsync] local rsyncclient receiver error: " + err.Error())
-		logRemotePathDiagnostics(ctx, sshCli, "source after rsync failure", job.Plan.Source.Path, w)
+		if ctx.Err() != nil {
+			w.Flush()
+			return ctx.Err()
+		}
+		logRemotePathDiagnostics(ctx, sshCli, "source after rsync failure", src.Path, w)
 		w.Flush()
 		return fmt.Errorf("rsyncclient.Run(receiver): %w", err)
 	}
+	if res != nil && res.Stats != nil && res.Stats.Size > 0 {
+		// 接收端事先不知道总量，结束时用 rsync 统计补上
+		job.setProgressTotals(res.Stats.Size, 0)
+	}
 
-	if err := sess.Wait(); err != nil {
+	if err := waitSession(ctx, sess); err != nil {
 		w.appendLine("[go-rsync] remote rsync server exit error: " + err.Error())
-		logRemotePathDiagnostics(ctx, sshCli, "source after remote rsync exit", job.Plan.Source.Path, w)
+		if ctx.Err() != nil {
+			w.Flush()
+			return ctx.Err()
+		}
+		logRemotePathDiagnostics(ctx, sshCli, "source after remote rsync exit", src.Path, w)
 		w.Flush()
 		return fmt.Errorf("remote rsync server exit: %w", err)
 	}
+	job.setStats(goRsyncStats(job, res))
 
 	w.Flush()
 	return nil
@@ -803,8 +1264,6 @@ func (m *JobManager) runRemoteToLocal_GoRsync(job *Job, ctx context.Context) err
 //
 // ============================
 func (m *JobManager) runRemoteToRemote_OneHopSSH(job *Job, ctx context.Context) error {
-	useLan := decideUseLan(job.Request)
-
 	plan := job.Plan
 	req := job.Request
 
@@ -825,7 +1284,7 @@ func (m *JobManager) runRemoteToRemote_OneHopSSH(job *Job, ctx context.Context)
 	execIsSource := plan.ExecHost == plan.Source.HostName
 	execIsDest := plan.ExecHost == plan.Dest.HostName
 	if !execIsSource && !execIsDest {
-		return fmt.Errorf("execHost must be source or dest for now, got %q", plan.ExecHost)
+		return fmt.Errorf("execHost must be source or dest in %s mode, got %q", plan.Mode, plan.ExecHost)
 	}
 
 	// innerTarget = execHost 要连接的“另一端”
@@ -837,18 +1296,16 @@ func (m *JobManager) runRemoteToRemote_OneHopSSH(job *Job, ctx context.Context)
 	}
 
 	// 连接 execHost（第一跳）
-	execDial := dialFor(&execHost.Config, false)
+	execDial, execLan := plan.dial("local", execHost)
 
 	w := &jobLineWriter{job: job}
-	job.mu.Lock()
-	job.LogLines = append(job.LogLines,
-		fmt.Sprintf("[remote-remote] first hop (control->execHost) %s@%s:%d (LAN=%v, forced WAN)",
-			execHost.Config.User, execDial.Host, execDial.Port, useLan,
+	job.appendLog(
+		fmt.Sprintf("[remote-remote] first hop (control->execHost) %s@%s:%d (LAN=%v)",
+			execHost.Config.User, execDial.Host, execDial.Port, execLan,
 		),
 	)
-	job.mu.Unlock()
 
-	sshCli, err := sshDial(&execHost.Config, execDial, false)
+	sshCli, _, err := dialHop(ctx, job, execHost)
 	if err != nil {
 		return err
 	}
@@ -867,6 +1324,8 @@ func (m *JobManager) runRemoteToRemote_OneHopSSH(job *Job, ctx context.Context)
 		return err
 	}
 	defer sess.Close()
+	stopCancel := closeSessionOnCancel(ctx, sess)
+	defer stopCancel()
 
 	if innerTarget.Config.Auth == "private_key" && innerTarget.Config.KeyPath != "" {
 		if err := agent.RequestAgentForwarding(sess); err != nil {
@@ -874,41 +1333,55 @@ func (m *JobManager) runRemoteToRemote_OneHopSSH(job *Job, ctx context.Context)
 		}
 	}
 
+	ow := newRsyncOutputWriter(job, w)
 	stdout, _ := sess.StdoutPipe()
 	stderr, _ := sess.StderrPipe()
-	go io.Copy(w, stdout)
-	go io.Copy(w, stderr)
 
 	// 组 inner ssh / rsync 命令
-	innerDial := dialFor(&innerTarget.Config, useLan)
-	innerSSH := buildInnerSSHCommand(innerTarget.Config, innerDial, useLan)
+	innerDial, innerLan := plan.dial(execHost.Config.Name, innerTarget)
+	innerSSH := buildInnerSSHCommand(innerTarget.Config, innerDial, innerLan)
 
 	args := buildRsyncArgs(&req.Options)
+	args = appendResumeArgs(args, &req.Retry)
 	args = append(args, "--protect-args", "-e", innerSSH)
 
+	srcPath, selArgs, filesFrom := selectionCLI(&req.Options, plan.Source)
+	args = append(args, selArgs...)
+
 	var srcSpec, dstSpec string
 	if execIsSource {
 		// execHost=source：src 是本地路径；dst 是 user@host:path
-		srcSpec = plan.Source.Path
+		srcSpec = srcPath
 		dstSpec = fmt.Sprintf("%s@%s:%s", innerTarget.Config.User, innerDial.Host, plan.Dest.Path)
 	} else {
 		// execHost=dest：src 是 user@host:path；dst 是本地路径
-		srcSpec = fmt.Sprintf("%s@%s:%s", innerTarget.Config.User, innerDial.Host, plan.Source.Path)
+		srcSpec = fmt.Sprintf("%s@%s:%s", innerTarget.Config.User, innerDial.Host, srcPath)
 		dstSpec = plan.Dest.Path
 	}
 
-	cmdStr := "rsync " + joinShellArgs(args) + " " + shQuote(srcSpec) + " " + shQuote(dstSpec)
+	args = append(args, progressArgs(job, execHost, sshCli)...)
+	args = append(args, "--stats")
+	cmdStr := rsyncCommandLine(args, filesFrom, srcSpec, dstSpec)
 
-	job.mu.Lock()
-	job.LogLines = append(job.LogLines, "[remote-remote] "+cmdStr)
-	job.mu.Unlock()
+	job.appendLog("[remote-remote] " + cmdStr)
 
-	if err := sess.Start("bash -lc " + shQuote(cmdStr)); err != nil {
+	// exec：让 rsync 直接成为 session 进程，取消时 SIGTERM 能打到它
+	if err := sess.Start("bash -lc " + shQuote("exec "+cmdStr)); err != nil {
 		w.Flush()
 		return fmt.Errorf("start remote command: %w", err)
 	}
-	err = sess.Wait()
+	// 输出读完再取 --stats，免得最后几行还没进 ow
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() { defer wg.Done(); _, _ = io.Copy(ow, stdout) }()
+	go func() { defer wg.Done(); _, _ = io.Copy(w, stderr) }()
+	err = waitSession(ctx, sess)
+	wg.Wait()
+	ow.Flush()
 	w.Flush()
+	if st := ow.Stats(); st != nil {
+		job.setStats(st)
+	}
 	return err
 }
 
@@ -933,6 +1406,14 @@ func forwardKeysToExecHost(sshCli *ssh.Client, keyPaths []string) error {
 
 // ===== SSH Dial（Go 内置，支持 key 或 password）=====
 func sshDial(cfg *HostConfig, d DialTarget, useLan bool) (*ssh.Client, error) {
+	return sshDialContext(context.Background(), cfg, d, useLan)
+}
+
+// sshDialContext：ctx 取消时中断 TCP 连接和握手
+func sshDialContext(ctx context.Context, cfg *HostConfig, d DialTarget, useLan bool) (cli *ssh.Client, err error) {
+	start := time.Now()
+	defer func() { observeSSHDial(cfg.Name, start, err) }()
+
 	addr := net.JoinHostPort(d.Host, strconv.Itoa(d.Port))
 
 	auths, err := buildSSHAuthMethods(cfg)
@@ -952,12 +1433,20 @@ func sshDial(cfg *HostConfig, d DialTarget, useLan bool) (*ssh.Client, error) {
 		cc.Ciphers = []string{"aes128-gcm@openssh.com", "aes128-ctr", "aes192-ctr", "aes256-ctr"}
 	}
 
-	netConn, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	netConn, err := dialer.DialContext(ctx, "tcp", addr)
 	if err != nil {
 		return nil, fmt.Errorf("dial %s: %w", addr, err)
 	}
 
+	stopCancel := context.AfterFunc(ctx, func() { _ = netConn.Close() })
 	cconn, chans, reqs, err := ssh.NewClientConn(netConn, addr, cc)
+	if !stopCancel() {
+		if err == nil {
+			_ = cconn.Close()
+		}
+		return nil, fmt.Errorf("ssh handshake %s: %w", addr, ctx.Err())
+	}
 	if err != nil {
 		_ = netConn.Close()
 		return nil, fmt.Errorf("ssh handshake %s: %w", addr, err)
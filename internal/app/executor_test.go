@@ -0,0 +1,127 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTree：在 root 下按相对路径建文件（中间目录自动创建）
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, body := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRunLocalLocal(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "src")
+	dst := filepath.Join(tmp, "dst")
+	writeTree(t, src, map[string]string{
+		"a.txt":     "hello",
+		"sub/b.txt": strings.Repeat("x", 100000),
+	})
+	writeTree(t, dst, map[string]string{"stale.txt": "old"})
+
+	reg, err := NewHostRegistry(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &App{Hosts: reg}
+	plan, err := a.PlanTransfer(TransferRequest{
+		EndpointA: Endpoint{HostName: "local", Path: src + "/"},
+		EndpointB: Endpoint{HostName: "local", Path: dst},
+		Direction: "A_to_B",
+		Options:   RsyncOptions{Archive: true, Delete: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Mode != ExecLocal {
+		t.Fatalf("mode = %s", plan.Mode)
+	}
+
+	m, err := NewJobManager(reg, JobManagerOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := &Job{Request: TransferRequest{Options: RsyncOptions{Archive: true, Delete: true}}, Plan: *plan}
+	if err := m.runLocalLocal(job, context.Background()); err != nil {
+		t.Fatalf("runLocalLocal: %v\n%s", err, strings.Join(job.LogLines, "\n"))
+	}
+
+	got, err := os.ReadFile(filepath.Join(dst, "sub", "b.txt"))
+	if err != nil || len(got) != 100000 {
+		t.Fatalf("sub/b.txt: %d bytes, %v", len(got), err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dst, "a.txt")); string(got) != "hello" {
+		t.Fatalf("a.txt = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "stale.txt")); !os.IsNotExist(err) {
+		t.Fatalf("stale.txt should be deleted, stat err = %v", err)
+	}
+	if job.Stats == nil || job.Stats.TotalFileSize < 100005 {
+		t.Fatalf("stats = %+v", job.Stats)
+	}
+}
+
+func TestRunLocalLocalSelection(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "src")
+	dst := filepath.Join(tmp, "dst")
+	writeTree(t, src, map[string]string{
+		"a.txt":     "a.txt",
+		"skip.txt":  "skip.txt",
+		"sub/b.txt": "sub/b.txt",
+	})
+
+	reg, err := NewHostRegistry(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &App{Hosts: reg}
+	req := TransferRequest{
+		EndpointA: Endpoint{HostName: "local", Path: src, Files: []string{"a.txt", "sub/"}},
+		EndpointB: Endpoint{HostName: "local", Path: dst},
+		Direction: "A_to_B",
+		Options:   RsyncOptions{Archive: true},
+	}
+	for _, files := range [][]string{{"../x"}, {"/etc/passwd"}, {"a/x", "b/x"}} {
+		bad := req
+		bad.EndpointA.Files = files
+		if _, err := a.PlanTransfer(bad); err == nil {
+			t.Fatalf("files %q: expected error", files)
+		}
+	}
+	plan, err := a.PlanTransfer(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := NewJobManager(reg, JobManagerOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := &Job{Request: req, Plan: *plan}
+	if err := m.runLocalLocal(job, context.Background()); err != nil {
+		t.Fatalf("runLocalLocal: %v\n%s", err, strings.Join(job.LogLines, "\n"))
+	}
+
+	for name, want := range map[string]string{"a.txt": "a.txt", "sub/b.txt": "sub/b.txt"} {
+		if got, _ := os.ReadFile(filepath.Join(dst, name)); string(got) != want {
+			t.Fatalf("%s = %q", name, got)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dst, "skip.txt")); !os.IsNotExist(err) {
+		t.Fatalf("skip.txt should not be copied, stat err = %v", err)
+	}
+}
@@ -0,0 +1,256 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ===== 扇出：一个源同时同步到多个目标 =====
+//
+// TransferRequest.FanOut 非空时就是扇出请求：源按 Direction 取（另一端留空），目标列表在 FanOut.Destinations。
+// 提交后生成一个父 Job（plan.Mode = fan_out），照常进任务历史、能取消、有事件流和通知；
+// 它不占执行槽位，只负责给每个目标各走一遍 PlanTransfer + 预检查、生成子 Job（Job.FanOut 指回父任务），
+// 按 Parallelism 控制同时跑几个，最后把每个目标的结果汇总到 Job.FanOutResult。
+// 子 Job 之间互不影响：某个目标失败不会取消其他目标；取消父任务会取消所有没结束的子任务。
+//
+//	{"endpointA": {"hostName": "build", "path": "/srv/dist/"}, "direction": "A_to_B",
+//	 "fanOut": {"destinations": [{"hostName": "web1", "path": "/var/www/"}, {"hostName": "web2", "path": "/var/www/"}],
+//	            "parallelism": 2},
+//	 "options": {...}}
+
+// FanOutSpec：扇出的目标列表；请求里其余字段（options、retry、hooks 等）对每个目标都一样
+type FanOutSpec struct {
+	Destinations []Endpoint `json:"destinations" yaml:"destinations"`
+	Parallelism  int        `json:"parallelism" yaml:"parallelism"` // 同时跑几个目标，0 = 全部（仍受全局 -max-jobs 限制）
+}
+
+// FanOutTarget：一个目标的执行结果
+type FanOutTarget struct {
+	Dest   Endpoint  `json:"dest"`
+	Status JobStatus `json:"status"`
+	JobID  string    `json:"jobId,omitempty"`
+	Error  string    `json:"error,omitempty"` // 计划/预检查失败时没有子 Job，原因记在这里
+}
+
+// FanOutResult：父任务上的汇总；任一目标失败父任务即 failed
+type FanOutResult struct {
+	Targets   []FanOutTarget `json:"targets"`
+	Succeeded int            `json:"succeeded"`
+	Failed    int            `json:"failed"`
+	Cancelled int            `json:"cancelled"`
+}
+
+func (r *FanOutResult) clone() *FanOutResult {
+	if r == nil {
+		return nil
+	}
+	cp := *r
+	cp.Targets = append([]FanOutTarget(nil), r.Targets...)
+	return &cp
+}
+
+// interrupt：进程退出时还没结束的目标（从历史加载时调用）
+func (r *FanOutResult) interrupt() {
+	for i := range r.Targets {
+		if !r.Targets[i].Status.IsFinished() {
+			r.Targets[i].Status = JobInterrupted
+		}
+	}
+}
+
+// fanOutSource：按 Direction 取源端；另一端必须留空，目标都在 FanOut.Destinations 里
+func fanOutSource(req *TransferRequest) (src, other Endpoint, err error) {
+	switch req.Direction {
+	case "A_to_B":
+		return req.EndpointA, req.EndpointB, nil
+	case "B_to_A":
+		return req.EndpointB, req.EndpointA, nil
+	default:
+		return Endpoint{}, Endpoint{}, fmt.Errorf("invalid direction: %s", req.Direction)
+	}
+}
+
+// planFanOut：只查结构和主机名；具体能不能传由每个目标自己的 PlanTransfer/预检查决定
+func (a *App) planFanOut(req TransferRequest) (*TransferPlan, error) {
+	src, other, err := fanOutSource(&req)
+	if err != nil {
+		return nil, err
+	}
+	if other.HostName != "" || other.Path != "" {
+		return nil, fmt.Errorf("fan-out: leave the dest endpoint empty and list destinations in fanOut.destinations")
+	}
+	if src.HostName == "" || src.Path == "" {
+		return nil, fmt.Errorf("fan-out: source needs hostName and path")
+	}
+	if _, ok := a.Hosts.Get(src.HostName); !ok {
+		return nil, fmt.Errorf("unknown source host: %s", src.HostName)
+	}
+	spec := req.FanOut
+	if len(spec.Destinations) == 0 {
+		return nil, fmt.Errorf("fan-out: no destinations")
+	}
+	if spec.Parallelism < 0 {
+		return nil, fmt.Errorf("fan-out: parallelism must not be negative")
+	}
+	if err := validateHooks(&req); err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(spec.Destinations))
+	for _, d := range spec.Destinations {
+		if d.HostName == "" || d.Path == "" {
+			return nil, fmt.Errorf("fan-out: destination needs hostName and path")
+		}
+		if _, ok := a.Hosts.Get(d.HostName); !ok {
+			return nil, fmt.Errorf("unknown dest host: %s", d.HostName)
+		}
+		if err := validateSelection(src, d); err != nil {
+			return nil, err
+		}
+		key := d.HostName + ":" + d.Path
+		if seen[key] {
+			return nil, fmt.Errorf("fan-out: duplicate destination %s", key)
+		}
+		seen[key] = true
+	}
+
+	parallel := "all at once"
+	if spec.Parallelism > 0 && spec.Parallelism < len(spec.Destinations) {
+		parallel = fmt.Sprintf("%d at a time", spec.Parallelism)
+	}
+	return &TransferPlan{
+		Mode:      ExecFanOut,
+		Source:    src,
+		ExecHost:  "local",
+		CreatedAt: time.Now(),
+		Reason:    fmt.Sprintf("fan-out to %d destinations, %s; each destination is planned when it starts", len(spec.Destinations), parallel),
+	}, nil
+}
+
+// fanOutChild：某个目标对应的普通传输请求
+func fanOutChild(req TransferRequest, src, dest Endpoint) TransferRequest {
+	req.EndpointA = src
+	req.EndpointB = dest
+	req.Direction = "A_to_B"
+	req.FanOut = nil
+	return req
+}
+
+// startFanOut：父任务不进队列（它只是等子任务，占着槽位会和子任务互相等死）
+func (a *App) startFanOut(job *Job) {
+	job.mu.Lock()
+	res := &FanOutResult{}
+	for _, d := range job.Request.FanOut.Destinations {
+		res.Targets = append(res.Targets, FanOutTarget{Dest: d, Status: JobPending})
+	}
+	job.FanOutResult = res
+	job.mu.Unlock()
+
+	a.JobManager.startUnqueued(job, func(ctx context.Context) JobStatus {
+		return a.runFanOut(ctx, job)
+	})
+}
+
+func (a *App) runFanOut(ctx context.Context, job *Job) JobStatus {
+	job.mu.Lock()
+	req := job.Request
+	src := job.Plan.Source
+	n := len(job.FanOutResult.Targets)
+	job.mu.Unlock()
+	job.appendLog("[plan] " + job.Plan.Reason)
+
+	limit := req.FanOut.Parallelism
+	if limit <= 0 || limit > n {
+		limit = n
+	}
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			job.setFanOutTarget(i, func(t *FanOutTarget) { t.Status = JobCancel })
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			a.runFanOutTarget(ctx, job, req, src, i)
+		}()
+	}
+	wg.Wait()
+
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	r := job.FanOutResult
+	r.Succeeded, r.Failed, r.Cancelled = 0, 0, 0
+	for _, t := range r.Targets {
+		switch t.Status {
+		case JobOK:
+			r.Succeeded++
+		case JobCancel:
+			r.Cancelled++
+		default:
+			r.Failed++
+		}
+	}
+	job.appendLogLocked(fmt.Sprintf("[fan-out] %d targets: %d ok, %d failed, %d cancelled", n, r.Succeeded, r.Failed, r.Cancelled))
+	switch {
+	case r.Failed > 0:
+		return JobFailed
+	case r.Cancelled > 0:
+		return JobCancel
+	default:
+		return JobOK
+	}
+}
+
+// runFanOutTarget：提交一个目标的子 Job 并等它结束
+func (a *App) runFanOutTarget(ctx context.Context, job *Job, req TransferRequest, src Endpoint, i int) {
+	job.mu.Lock()
+	dest := job.FanOutResult.Targets[i].Dest
+	job.mu.Unlock()
+	name := dest.HostName + ":" + dest.Path
+
+	job.setFanOutTarget(i, func(t *FanOutTarget) { t.Status = JobRunning })
+	sub, err := a.SubmitTransfer(fanOutChild(req, src, dest), SubmitOptions{FanOut: job.ID})
+	if err != nil {
+		if sub != nil && sub.Precheck != nil && sub.Precheck.Message != "" {
+			err = fmt.Errorf("%w: %s", err, sub.Precheck.Message)
+		}
+		job.appendLog(fmt.Sprintf("[fan-out] %s: %v", name, err))
+		job.setFanOutTarget(i, func(t *FanOutTarget) {
+			t.Status = JobFailed
+			t.Error = err.Error()
+		})
+		return
+	}
+	job.appendLog(fmt.Sprintf("[fan-out] %s: job %s", name, sub.Job.ID))
+	job.setFanOutTarget(i, func(t *FanOutTarget) { t.JobID = sub.Job.ID })
+	a.JobManager.persist(job)
+
+	status := a.JobManager.waitJob(ctx, sub.Job)
+	job.appendLog(fmt.Sprintf("[fan-out] %s: %s", name, status))
+	job.setFanOutTarget(i, func(t *FanOutTarget) { t.Status = status })
+}
+
+func (j *Job) setFanOutTarget(i int, fn func(*FanOutTarget)) {
+	j.mu.Lock()
+	fn(&j.FanOutResult.Targets[i])
+	j.notifyLocked()
+	j.mu.Unlock()
+}
+
+// fanOutPreview：预览里列出每个目标；具体命令要等各自规划后才知道
+func fanOutPreview(req TransferRequest, plan *TransferPlan) string {
+	var b strings.Builder
+	b.WriteString("# " + plan.Reason + "\n")
+	for _, d := range req.FanOut.Destinations {
+		fmt.Fprintf(&b, "# %s:%s -> %s:%s\n", plan.Source.HostName, plan.Source.Path, d.HostName, d.Path)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
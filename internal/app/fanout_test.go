@@ -0,0 +1,146 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newFanOutTestApp(t *testing.T) (*App, string) {
+	t.Helper()
+	tmp := t.TempDir()
+	writeTree(t, filepath.Join(tmp, "src"), map[string]string{"a.txt": "hello"})
+	reg, err := NewHostRegistry(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jm, err := NewJobManager(reg, JobManagerOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &App{Hosts: reg, JobManager: jm}, tmp
+}
+
+func waitJobFinished(t *testing.T, m *JobManager, job *Job) *Job {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	m.waitJob(ctx, job)
+	if ctx.Err() != nil {
+		t.Fatalf("job %s did not finish: %+v", job.ID, job.Snapshot(20))
+	}
+	return job.Snapshot(-1)
+}
+
+func TestFanOutAggregates(t *testing.T) {
+	a, tmp := newFanOutTestApp(t)
+	// 普通文件下面建不了目录，这个目标预检查会失败
+	writeTree(t, tmp, map[string]string{"file": ""})
+	blocker := filepath.Join(tmp, "file")
+
+	req := TransferRequest{
+		EndpointA: Endpoint{HostName: "local", Path: filepath.Join(tmp, "src") + "/"},
+		Direction: "A_to_B",
+		Options:   RsyncOptions{Archive: true},
+		FanOut:    &FanOutSpec{},
+	}
+	if _, err := a.SubmitTransfer(req, SubmitOptions{}); err == nil {
+		t.Fatal("expected error for no destinations")
+	}
+
+	dests := []Endpoint{
+		{HostName: "local", Path: filepath.Join(tmp, "d1")},
+		{HostName: "local", Path: filepath.Join(blocker, "d2")},
+		{HostName: "local", Path: filepath.Join(tmp, "d3")},
+	}
+	req.FanOut = &FanOutSpec{Destinations: dests, Parallelism: 2}
+	sub, err := a.SubmitTransfer(req, SubmitOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.Plan.Mode != ExecFanOut {
+		t.Fatalf("mode = %s", sub.Plan.Mode)
+	}
+
+	// 父任务就是普通的 Job：在任务列表里，结束后有汇总
+	snap := waitJobFinished(t, a.JobManager, sub.Job)
+	res := snap.FanOutResult
+	if snap.Status != JobFailed || res.Succeeded != 2 || res.Failed != 1 {
+		t.Fatalf("status = %s, result = %+v", snap.Status, res)
+	}
+	if tg := res.Targets[1]; tg.JobID != "" || tg.Error == "" {
+		t.Fatalf("target 2 = %+v", tg)
+	}
+	for _, i := range []int{0, 2} {
+		job, ok := a.JobManager.GetJob(res.Targets[i].JobID)
+		if !ok || job.FanOut != sub.Job.ID || job.Request.FanOut != nil {
+			t.Fatalf("target %d job = %+v", i, res.Targets[i])
+		}
+		if got, _ := os.ReadFile(filepath.Join(dests[i].Path, "a.txt")); string(got) != "hello" {
+			t.Fatalf("%s/a.txt = %q", dests[i].Path, got)
+		}
+	}
+	if page, err := a.JobManager.QueryJobs(JobQuery{FanOut: sub.Job.ID}); err != nil || len(page.Jobs) != 2 {
+		t.Fatalf("children query = %+v, %v", page, err)
+	}
+}
+
+func TestFanOutCancel(t *testing.T) {
+	a, tmp := newFanOutTestApp(t)
+	// 子任务一直跑到被取消为止
+	a.JobManager.runFn = func(ctx context.Context, job *Job) {
+		<-ctx.Done()
+		job.mu.Lock()
+		job.finishLocked(JobCancel)
+		job.mu.Unlock()
+	}
+
+	var dests []Endpoint
+	for _, d := range []string{"d1", "d2", "d3"} {
+		dests = append(dests, Endpoint{HostName: "local", Path: filepath.Join(tmp, d)})
+	}
+	sub, err := a.SubmitTransfer(TransferRequest{
+		EndpointA: Endpoint{HostName: "local", Path: filepath.Join(tmp, "src") + "/"},
+		Direction: "A_to_B",
+		FanOut:    &FanOutSpec{Destinations: dests, Parallelism: 2},
+	}, SubmitOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		started := 0
+		for _, tg := range sub.Job.Snapshot(0).FanOutResult.Targets {
+			if tg.JobID != "" {
+				started++
+			}
+		}
+		if started == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("children did not start: %+v", sub.Job.Snapshot(20))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// 和普通任务一样通过 CancelJob 取消：没结束的子任务跟着取消，没开始的不再提交
+	if _, err := a.JobManager.CancelJob(sub.Job.ID); err != nil {
+		t.Fatal(err)
+	}
+	snap := waitJobFinished(t, a.JobManager, sub.Job)
+	if snap.Status != JobCancel || snap.FanOutResult.Cancelled != 3 {
+		t.Fatalf("status = %s, result = %+v", snap.Status, snap.FanOutResult)
+	}
+	for _, tg := range snap.FanOutResult.Targets[:2] {
+		if job, ok := a.JobManager.GetJob(tg.JobID); !ok || job.CurrentStatus() != JobCancel {
+			t.Fatalf("child %s not cancelled", tg.JobID)
+		}
+	}
+	if tg := snap.FanOutResult.Targets[2]; tg.JobID != "" {
+		t.Fatalf("third target was submitted: %+v", tg)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 )
 
 // FSEntry 表示目录里的一个条目
@@ -46,6 +47,17 @@ func (a *App) ListDir(hostName, path string) (*FSListResult, error) {
 
 // ListDirEx：可选同时预取 1 级子目录内容
 func (a *App) ListDirEx(hostName, path string, prefetch bool, maxChildren int) (*FSListResult, error) {
+	start := time.Now()
+	res, err := a.listDirEx(hostName, path, prefetch, maxChildren)
+	if err != nil {
+		metrics.add(mFSListFailures, 1, hostName)
+	} else {
+		metrics.observe(mFSList, time.Since(start).Seconds(), hostName)
+	}
+	return res, err
+}
+
+func (a *App) listDirEx(hostName, path string, prefetch bool, maxChildren int) (*FSListResult, error) {
 	h, ok := a.Hosts.Get(hostName)
 	if !ok {
 		return nil, fmt.Errorf("unknown host %q", hostName)
@@ -0,0 +1,118 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ===== 传输前/后的 hook 命令 =====
+
+// HookCommand：在源/目标/执行机/本机上跑的一条 shell 命令
+type HookCommand struct {
+	Host           string `json:"host" yaml:"host"` // "source" / "dest" / "exec" / "local"，默认 exec
+	Command        string `json:"command" yaml:"command"`
+	When           string `json:"when" yaml:"when"`                     // 只对 postCommand 有效："success"（默认）/ "always"
+	TimeoutSeconds int    `json:"timeoutSeconds" yaml:"timeoutSeconds"` // 0 = 不限制
+}
+
+const (
+	HookOnSuccess = "success"
+	HookAlways    = "always"
+
+	// 任务被取消后 always 的 post hook 仍然要跑（比如把停掉的服务拉起来），这时最多给它这么久
+	postHookAfterCancelTimeout = 5 * time.Minute
+)
+
+func (h *HookCommand) enabled() bool {
+	return h != nil && h.Command != ""
+}
+
+// hookHostName 把 source/dest/exec/local 换成 plan 里的主机名
+func hookHostName(plan *TransferPlan, target string) (string, error) {
+	switch target {
+	case "", "exec":
+		if plan.ExecHost == "" {
+			return "local", nil
+		}
+		return plan.ExecHost, nil
+	case "source":
+		return plan.Source.HostName, nil
+	case "dest":
+		return plan.Dest.HostName, nil
+	case "local":
+		return "local", nil
+	default:
+		return "", fmt.Errorf("unknown hook host %q (want source/dest/exec/local)", target)
+	}
+}
+
+// runHook 执行 hook，输出逐行写进任务日志
+func (m *JobManager) runHook(ctx context.Context, job *Job, kind string, hook *HookCommand) error {
+	name, err := hookHostName(&job.Plan, hook.Host)
+	if err != nil {
+		return err
+	}
+	h, err := m.getHost(name)
+	if err != nil {
+		return err
+	}
+	if hook.TimeoutSeconds > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(hook.TimeoutSeconds)*time.Second)
+		defer cancel()
+	}
+
+	job.appendLog(fmt.Sprintf("[%s-hook] on %s: %s", kind, name, hook.Command))
+	w := &jobLineWriter{job: job}
+	err = h.RunContext(ctx, hook.Command, w)
+	w.Flush()
+	if err != nil {
+		return err
+	}
+	job.appendLog(fmt.Sprintf("[%s-hook] done", kind))
+	return nil
+}
+
+// runPostHook：按 When 决定是否执行；transferErr 是传输本身的结果
+func (m *JobManager) runPostHook(ctx context.Context, job *Job, transferErr error) error {
+	hook := job.Request.PostCommand
+	if !hook.enabled() {
+		return nil
+	}
+	ok := transferErr == nil && ctx.Err() == nil
+	if !ok && hook.When != HookAlways {
+		job.appendLog("[post-hook] skipped: transfer did not succeed")
+		return nil
+	}
+	if ctx.Err() != nil {
+		timeout := postHookAfterCancelTimeout
+		if hook.TimeoutSeconds > 0 {
+			timeout = time.Duration(hook.TimeoutSeconds) * time.Second
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.WithoutCancel(ctx), timeout)
+		defer cancel()
+	}
+	return m.runHook(ctx, job, "post", hook)
+}
+
+func validateHooks(req *TransferRequest) error {
+	for _, c := range []struct {
+		name string
+		hook *HookCommand
+	}{{"preCommand", req.PreCommand}, {"postCommand", req.PostCommand}} {
+		if !c.hook.enabled() {
+			continue
+		}
+		if _, err := hookHostName(&TransferPlan{}, c.hook.Host); err != nil {
+			return fmt.Errorf("%s: %w", c.name, err)
+		}
+		switch c.hook.When {
+		case "", HookOnSuccess, HookAlways:
+		default:
+			return fmt.Errorf("%s: unknown when %q (want success/always)", c.name, c.hook.When)
+		}
+	}
+	return nil
+}
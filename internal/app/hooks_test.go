@@ -0,0 +1,84 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJobHooks(t *testing.T) {
+	reg, err := NewHostRegistry(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jm, err := NewJobManager(reg, JobManagerOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &App{Hosts: reg, JobManager: jm}
+
+	for _, tc := range []struct {
+		name     string
+		pre      string // 里面的 DST 换成目标目录
+		postWhen string
+		cancel   bool // pre hook 跑起来之后取消任务
+		status   JobStatus
+		copied   bool
+		postRan  bool
+	}{
+		{name: "success runs post", pre: "true", status: JobOK, copied: true, postRan: true},
+		{name: "pre failure aborts, skips success post", pre: "exit 3", status: JobFailed},
+		{name: "pre failure still runs always post", pre: "exit 3", postWhen: HookAlways, status: JobFailed, postRan: true},
+		// pre hook 把目标换成普通文件，传输本身失败
+		{name: "transfer failure skips success post", pre: "rm -rf DST && touch DST", status: JobFailed},
+		{name: "transfer failure runs always post", pre: "rm -rf DST && touch DST", postWhen: HookAlways, status: JobFailed, postRan: true},
+		{name: "cancel runs always post", pre: "exec sleep 30", postWhen: HookAlways, cancel: true, status: JobCancel, postRan: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tmp := t.TempDir()
+			src, dst := filepath.Join(tmp, "src"), filepath.Join(tmp, "dst")
+			marker := filepath.Join(tmp, "post-ran")
+			writeTree(t, src, map[string]string{"a.txt": "hello"})
+
+			sub, err := a.SubmitTransfer(TransferRequest{
+				EndpointA:   Endpoint{HostName: "local", Path: src + "/"},
+				EndpointB:   Endpoint{HostName: "local", Path: dst},
+				Direction:   "A_to_B",
+				Options:     RsyncOptions{Archive: true},
+				PreCommand:  &HookCommand{Host: "local", Command: strings.ReplaceAll(tc.pre, "DST", shQuote(dst))},
+				PostCommand: &HookCommand{Host: "local", Command: "touch " + shQuote(marker), When: tc.postWhen},
+			}, SubmitOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			job := sub.Job
+
+			if tc.cancel {
+				deadline := time.Now().Add(30 * time.Second)
+				for !strings.Contains(strings.Join(job.Snapshot(-1).LogLines, "\n"), "[pre-hook] on local") {
+					if time.Now().After(deadline) {
+						t.Fatal("pre-hook did not start")
+					}
+					time.Sleep(10 * time.Millisecond)
+				}
+				if _, err := jm.CancelJob(job.ID); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			snap := waitJobFinished(t, jm, job)
+			log := strings.Join(snap.LogLines, "\n")
+			if snap.Status != tc.status {
+				t.Fatalf("status = %s, want %s\n%s", snap.Status, tc.status, log)
+			}
+			if _, err := os.Stat(filepath.Join(dst, "a.txt")); (err == nil) != tc.copied {
+				t.Fatalf("copied = %v, want %v\n%s", err == nil, tc.copied, log)
+			}
+			if _, err := os.Stat(marker); (err == nil) != tc.postRan {
+				t.Fatalf("post-hook ran = %v, want %v\n%s", err == nil, tc.postRan, log)
+			}
+		})
+	}
+}
@@ -2,9 +2,10 @@ package app
 
 import (
 	"bytes"
-	"errors"
+	"context"
 	"fmt"
 	"golang.org/x/crypto/ssh"
+	"io"
 	"net"
 	"os"
 	"os/exec"
@@ -21,6 +22,9 @@ type Host struct {
 
 	sshMu     sync.Mutex
 	sshClient *ssh.Client
+
+	rsyncMu        sync.Mutex
+	rsyncProgress2 *bool // 这台主机上的 rsync 支不支持 --info=progress2，nil = 还没探测，见 progress.go
 }
 
 type HostRegistry struct {
@@ -29,6 +33,9 @@ type HostRegistry struct {
 	local  *Host
 }
 
+// reservedHostNames：ExecSide 的关键字（见 planner.go），主机叫这些名字就没法被选成中转机
+var reservedHostNames = map[string]bool{"auto": true, "source": true, "dest": true, "local": true, "stream": true}
+
 func NewHostRegistry(configs []HostConfig) (*HostRegistry, error) {
 	reg := &HostRegistry{
 		byName: make(map[string]*Host),
@@ -53,6 +60,9 @@ func NewHostRegistry(configs []HostConfig) (*HostRegistry, error) {
 		if hc.Name == "" {
 			return nil, fmt.Errorf("host name empty in config")
 		}
+		if reservedHostNames[hc.Name] {
+			return nil, fmt.Errorf("host name %q is reserved (used as an execSide keyword)", hc.Name)
+		}
 		if _, ok := reg.byName[hc.Name]; ok {
 			return nil, fmt.Errorf("duplicate host name: %s", hc.Name)
 		}
@@ -83,62 +93,81 @@ func (r *HostRegistry) Local() *Host {
 }
 
 func runSSHGo(h *Host, remoteCmd string) (string, error) {
+	var buf lockedBuffer
+	err := runSSHContext(context.Background(), h, remoteCmd, &buf, true)
+	out := buf.String()
+	// ✅ 永远打印 err（你之前排障痛点）
+	if err != nil {
+		fmt.Printf("[ssh] host=%s ERR=%v out=%q\n", h.Config.Name, err, out)
+		return out, fmt.Errorf("ssh run failed: %w", err)
+	}
+	fmt.Printf("[ssh] host=%s OK out=%q\n", h.Config.Name, out)
+	return out, nil
+}
+
+// sshSession：在 h 复用的连接上开一个 session；连接已经断了（开不了 session）就静默重连一次
+func sshSession(h *Host) (*ssh.Session, error) {
 	h.sshMu.Lock()
 	defer h.sshMu.Unlock()
 
-	runOnce := func() (string, error) {
-		client, err := getOrDialSSHClientLocked(h)
-		if err != nil {
-			return "", err
-		}
+	client, err := getOrDialSSHClientLocked(h)
+	if err != nil {
+		return nil, err
+	}
+	sess, err := client.NewSession()
+	if err == nil {
+		return sess, nil
+	}
+	closeSSHClientLocked(h)
+	if client, err = getOrDialSSHClientLocked(h); err != nil {
+		return nil, err
+	}
+	return client.NewSession()
+}
 
-		sess, err := client.NewSession()
-		if err != nil {
-			return "", err
-		}
-		defer sess.Close()
+// runSSHContext：runSSH 的底层，stdout/stderr 边跑边写到 out（out 要能并发写），ctx 取消时结束 session。
+// 命令开始后出错不重试（可能已经执行了一半）；只在拿连接时持有 sshMu，长命令不挡同一主机上的其他命令。
+// pty 给短的探测命令用，hook/流水线这种长命令不开
+func runSSHContext(ctx context.Context, h *Host, remoteCmd string, out io.Writer, pty bool) error {
+	sess, err := sshSession(h)
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
 
-		// PTY：可选，失败也别影响逻辑（但打印出来方便排查）
+	// PTY：可选，失败也别影响逻辑（但打印出来方便排查）
+	if pty {
 		if err := sess.RequestPty("xterm", 80, 40, ssh.TerminalModes{}); err != nil {
 			fmt.Printf("[ssh] host=%s pty denied: %v\n", h.Config.Name, err)
 		}
-
-		// ✅ 正常模式：不加任何哨兵，避免污染业务输出
-		cmd := "sh -c " + shellQuote(remoteCmd)
-		fmt.Printf("[ssh] host=%s cmd=%q\n", h.Config.Name, cmd)
-
-		b, err := sess.CombinedOutput(cmd)
-		out := string(b)
-
-		// ✅ 永远打印 err（你之前排障痛点）
-		if err != nil {
-			fmt.Printf("[ssh] host=%s ERR=%v out=%q\n", h.Config.Name, err, out)
-			return out, err
-		}
-
-		fmt.Printf("[ssh] host=%s OK out=%q\n", h.Config.Name, out)
-		return out, nil
 	}
 
-	out, err := runOnce()
-	if err == nil {
-		return out, nil
+	// ✅ 正常模式：不加任何哨兵，避免污染业务输出
+	cmd := "sh -c " + shellQuote(remoteCmd)
+	sess.Stdout = out
+	sess.Stderr = out
+	if err := sess.Start(cmd); err != nil {
+		return err
 	}
+	return waitSession(ctx, sess)
+}
 
-	// 远端命令退出码 != 0：属于业务错误，不要重连重试
-	var exitErr *ssh.ExitError
-	if errors.As(err, &exitErr) {
-		return out, fmt.Errorf("ssh run failed: %w", err)
-	}
+// lockedBuffer：stdout/stderr 两个 goroutine 同时写的缓冲
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
 
-	// 连接断开/复用连接失效：静默重连重试一次（用户不应看到错误）
-	closeSSHClientLocked(h)
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
 
-	out2, err2 := runOnce()
-	if err2 != nil {
-		return out2, fmt.Errorf("ssh run failed: %w", err2)
-	}
-	return out2, nil
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
 }
 
 func sshAuthMethods(cfg *HostConfig) ([]ssh.AuthMethod, error) {
@@ -190,7 +219,9 @@ func getOrDialSSHClientLocked(h *Host) (*ssh.Client, error) {
 	}
 
 	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	start := time.Now()
 	client, err := ssh.Dial("tcp", addr, clientCfg)
+	observeSSHDial(h.Config.Name, start, err)
 	if err != nil {
 		return nil, err
 	}
@@ -224,6 +255,18 @@ func runSSH(h *Host, remoteCmd string) (string, error) {
 	return runSSHGo(h, remoteCmd)
 }
 
+// runSSHCtx：runSSH 带 ctx 的版本，给要有截止时间的探测命令用；本机命令很快，不看 ctx
+func runSSHCtx(ctx context.Context, h *Host, remoteCmd string) (string, error) {
+	if h.IsLocal {
+		return runLocalShell(remoteCmd)
+	}
+	var buf lockedBuffer
+	if err := runSSHContext(ctx, h, remoteCmd, &buf, true); err != nil {
+		return buf.String(), fmt.Errorf("ssh run failed: %w", err)
+	}
+	return buf.String(), nil
+}
+
 // 简单本地 shell 执行（兼容 Windows / *nix）
 func runLocalShell(cmdStr string) (string, error) {
 	var cmd *exec.Cmd
@@ -249,3 +292,33 @@ func (h *Host) Run(cmdStr string) (string, error) {
 	}
 	return runSSHGo(h, cmdStr)
 }
+
+// RunContext：Host.Run 的流式版本，stdout/stderr 边跑边写到 out；ctx 取消时结束命令。
+// 远程复用 runSSH 的连接，不开 PTY，适合 hook、流水线步骤这种可能跑很久的命令。
+func (h *Host) RunContext(ctx context.Context, cmdStr string, out io.Writer) error {
+	if h.IsLocal {
+		var cmd *exec.Cmd
+		if runtime.GOOS == "windows" {
+			cmd = exec.CommandContext(ctx, "cmd", "/C", cmdStr)
+		} else {
+			cmd = exec.CommandContext(ctx, "bash", "-lc", cmdStr)
+		}
+		cmd.Stdout = out
+		cmd.Stderr = out
+		if err := cmd.Run(); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("local command: %w", err)
+		}
+		return nil
+	}
+
+	if err := runSSHContext(ctx, h, cmdStr, out, false); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("command on %s: %w", h.Config.Name, err)
+	}
+	return nil
+}
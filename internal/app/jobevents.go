@@ -0,0 +1,149 @@
+package app
+
+import (
+	"context"
+	"time"
+)
+
+// ===== Job 变化通知（日志/状态/进度），给 SSE 等订阅方用 =====
+//
+// 订阅方拿到的只是“有变化”的信号，具体内容再通过 EventsSince 按偏移量去读，
+// 这样慢订阅方不会丢行，也不会拖慢写日志的一方。
+
+// Subscribe 返回变化通知 channel 和取消订阅函数
+func (j *Job) Subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	j.mu.Lock()
+	if j.subs == nil {
+		j.subs = make(map[chan struct{}]struct{})
+	}
+	j.subs[ch] = struct{}{}
+	j.mu.Unlock()
+
+	return ch, func() {
+		j.mu.Lock()
+		delete(j.subs, ch)
+		j.mu.Unlock()
+	}
+}
+
+// notifyLocked 通知所有订阅方；调用方需持有 j.mu
+func (j *Job) notifyLocked() {
+	for ch := range j.subs {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// setStatusLocked 修改状态并通知订阅方；调用方需持有 j.mu
+func (j *Job) setStatusLocked(s JobStatus) {
+	j.Status = s
+	j.notifyLocked()
+}
+
+// JobEvents：某个偏移量之后的新日志，加上当前状态和进度
+type JobEvents struct {
+	Lines      []string  `json:"lines"`
+	Offset     int       `json:"offset"`     // Lines[0] 的行号
+	NextOffset int       `json:"nextOffset"` // 下次从这里继续读
+	More       bool      `json:"more"`       // 还有没读完的行（一次最多读一页）
+	Status     JobStatus `json:"status"`
+	StartedAt  time.Time `json:"startedAt"`
+	EndedAt    time.Time `json:"endedAt"`
+	Progress   Progress  `json:"progress"`
+}
+
+// EventsSince 读取 offset 之后的日志行和当前状态；offset 早于内存窗口时从日志文件补
+func (j *Job) EventsSince(offset int) JobEvents {
+	var ev JobEvents
+	page, err := j.ReadLog(offset, maxLogPageLines)
+	if err != nil {
+		// 日志文件读失败：跳到内存窗口继续推
+		j.mu.Lock()
+		start := j.logMemStartLocked()
+		j.mu.Unlock()
+		page, _ = j.ReadLog(start, maxLogPageLines)
+	}
+	if page != nil {
+		ev.Lines = page.Lines
+		ev.Offset = page.Offset
+		ev.NextOffset = page.NextOffset
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	ev.More = ev.NextOffset < j.LogTotal
+	ev.Status = j.Status
+	ev.StartedAt = j.StartedAt
+	ev.EndedAt = j.EndedAt
+	ev.Progress = j.Progress
+	return ev
+}
+
+// Snapshot 在锁内拷贝一份可以安全 JSON 编码的 Job；logTail >= 0 时只带最后 logTail 行
+func (j *Job) Snapshot(logTail int) *Job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	lines := j.LogLines
+	if logTail >= 0 && len(lines) > logTail {
+		lines = lines[len(lines)-logTail:]
+	}
+	return &Job{
+		ID:        j.ID,
+		ParentID:  j.ParentID,
+		Task:      j.Task,
+		Schedule:  j.Schedule,
+		Pipeline:  j.Pipeline,
+		FanOut:    j.FanOut,
+		Request:   j.Request,
+		Plan:      j.Plan,
+		Status:    j.Status,
+		CreatedAt: j.CreatedAt,
+		StartedAt: j.StartedAt,
+		EndedAt:   j.EndedAt,
+		LogLines:  append([]string(nil), lines...),
+		LogTotal:  j.LogTotal,
+		Attempts:  append([]JobAttempt(nil), j.Attempts...),
+		Progress:  j.Progress,
+		Stats:     j.Stats,
+
+		FanOutResult: j.FanOutResult.clone(),
+	}
+}
+
+// CurrentStatus：加锁读取当前状态
+func (j *Job) CurrentStatus() JobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.Status
+}
+
+// IsFinished：状态已经不会再变
+func (s JobStatus) IsFinished() bool {
+	return s != JobPending && s != JobRunning
+}
+
+// waitJob 等 Job 结束；ctx 取消时顺带取消它（流水线、扇出等着子 Job 时用）
+func (m *JobManager) waitJob(ctx context.Context, job *Job) JobStatus {
+	notify, unsubscribe := job.Subscribe()
+	defer unsubscribe()
+
+	cancelled := false
+	for {
+		if st := job.CurrentStatus(); st.IsFinished() {
+			return st
+		}
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			if !cancelled {
+				cancelled = true
+				_, _ = m.CancelJob(job.ID)
+			}
+			ctx = context.Background() // 取消后继续等它真正结束
+		}
+	}
+}
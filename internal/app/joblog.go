@@ -0,0 +1,237 @@
+package app
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ===== 任务日志：内存里只留最近 N 行，完整日志写到 logDir/<id>.log =====
+
+const (
+	defaultLogMemLines = 2000
+	logIndexStep       = 1024 // 每隔这么多行记一次文件偏移，分页读时直接 Seek
+	maxLogPageLines    = 5000
+)
+
+type jobLog struct {
+	max   int    // 内存里最多保留的行数
+	path  string // 为空表示不落盘
+	f     *os.File
+	w     *bufio.Writer
+	bytes int64   // 已写入的字节数
+	index []int64 // index[k] = 第 k*logIndexStep 行在文件里的偏移
+}
+
+func jobLogPath(dir, id string) string {
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, id+".log")
+}
+
+// openJobLog：新任务创建日志文件；打不开就只存内存
+func openJobLog(dir, id string, maxMem int) jobLog {
+	l := jobLog{max: maxMem}
+	if dir == "" {
+		return l
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Printf("[joblog] mkdir %s: %v", dir, err)
+		return l
+	}
+	path := jobLogPath(dir, id)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		log.Printf("[joblog] open %s: %v", path, err)
+		return l
+	}
+	l.path = path
+	l.f = f
+	l.w = bufio.NewWriterSize(f, 64*1024)
+	return l
+}
+
+func (l *jobLog) memLines() int {
+	if l.max > 0 {
+		return l.max
+	}
+	return defaultLogMemLines
+}
+
+func (l *jobLog) writeLine(lineNo int, line string) {
+	if l.w == nil {
+		return
+	}
+	if lineNo%logIndexStep == 0 {
+		l.index = append(l.index, l.bytes)
+	}
+	n, err := l.w.WriteString(line + "\n")
+	l.bytes += int64(n)
+	if err != nil {
+		log.Printf("[joblog] write %s: %v", l.path, err)
+		l.close()
+	}
+}
+
+func (l *jobLog) flush() {
+	if l.w != nil {
+		_ = l.w.Flush()
+	}
+}
+
+func (l *jobLog) close() {
+	if l.f == nil {
+		return
+	}
+	_ = l.w.Flush()
+	_ = l.f.Close()
+	l.f = nil
+	l.w = nil
+}
+
+// appendLogLocked 追加日志行（含换行的拆成多行）并通知订阅方；调用方需持有 j.mu
+func (j *Job) appendLogLocked(lines ...string) {
+	if len(lines) == 0 {
+		return
+	}
+	for _, line := range lines {
+		for _, l := range strings.Split(line, "\n") {
+			j.logSink.writeLine(j.LogTotal, l)
+			j.LogTotal++
+			j.LogLines = append(j.LogLines, l)
+		}
+	}
+	if max := j.logSink.memLines(); len(j.LogLines) > max {
+		j.LogLines = j.LogLines[len(j.LogLines)-max:]
+	}
+	j.notifyLocked()
+}
+
+func (j *Job) appendLog(lines ...string) {
+	j.mu.Lock()
+	j.appendLogLocked(lines...)
+	j.mu.Unlock()
+}
+
+// finishLocked：任务进入终态，关闭日志文件；调用方需持有 j.mu
+func (j *Job) finishLocked(status JobStatus) {
+	j.setStatusLocked(status)
+	j.EndedAt = time.Now()
+	j.logSink.close()
+}
+
+// logMemStartLocked：内存里第一行的行号
+func (j *Job) logMemStartLocked() int {
+	return j.LogTotal - len(j.LogLines)
+}
+
+// JobLogPage：分页读日志的结果
+type JobLogPage struct {
+	Offset     int      `json:"offset"`
+	Lines      []string `json:"lines"`
+	NextOffset int      `json:"nextOffset"`
+	Total      int      `json:"total"`
+}
+
+// ReadLog 按行号分页读日志：内存窗口内直接给，更早的从日志文件读
+func (j *Job) ReadLog(offset, limit int) (*JobLogPage, error) {
+	if limit <= 0 || limit > maxLogPageLines {
+		limit = maxLogPageLines
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	j.mu.Lock()
+	total := j.LogTotal
+	memStart := j.logMemStartLocked()
+	if offset >= memStart || j.logSink.path == "" {
+		if offset < memStart {
+			offset = memStart // 没有落盘，更早的行已经丢了
+		}
+		if offset > total {
+			offset = total
+		}
+		from := offset - memStart
+		to := from + limit
+		if to > len(j.LogLines) {
+			to = len(j.LogLines)
+		}
+		lines := make([]string, to-from)
+		copy(lines, j.LogLines[from:to])
+		j.mu.Unlock()
+		return &JobLogPage{Offset: offset, Lines: lines, NextOffset: offset + len(lines), Total: total}, nil
+	}
+
+	j.logSink.flush()
+	path := j.logSink.path
+	index := append([]int64(nil), j.logSink.index...)
+	j.mu.Unlock()
+
+	lines, err := readLogFileLines(path, index, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	return &JobLogPage{Offset: offset, Lines: lines, NextOffset: offset + len(lines), Total: total}, nil
+}
+
+// OpenLog 打开完整日志用于下载；没落盘的任务返回内存里的行
+func (j *Job) OpenLog() (io.ReadCloser, error) {
+	j.mu.Lock()
+	path := j.logSink.path
+	if path == "" {
+		data := strings.Join(j.LogLines, "\n") + "\n"
+		j.mu.Unlock()
+		return io.NopCloser(strings.NewReader(data)), nil
+	}
+	j.logSink.flush()
+	j.mu.Unlock()
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open job log: %w", err)
+	}
+	return f, nil
+}
+
+func readLogFileLines(path string, index []int64, offset, limit int) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open job log: %w", err)
+	}
+	defer f.Close()
+
+	skip := offset
+	if k := offset / logIndexStep; k > 0 && k < len(index) {
+		if _, err := f.Seek(index[k], io.SeekStart); err != nil {
+			return nil, fmt.Errorf("seek job log: %w", err)
+		}
+		skip = offset - k*logIndexStep
+	}
+
+	br := bufio.NewReaderSize(f, 64*1024)
+	lines := make([]string, 0, min(limit, 1024))
+	for len(lines) < limit {
+		s, err := br.ReadString('\n')
+		if err != nil {
+			// 最后一行还没写完整：当作没读到
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("read job log: %w", err)
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		lines = append(lines, strings.TrimSuffix(s, "\n"))
+	}
+	return lines, nil
+}
@@ -0,0 +1,106 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReadLog(t *testing.T) {
+	const total, mem = 3000, 100 // 跨过几个 logIndexStep，内存里只剩最后 100 行
+	line := func(i int) string { return fmt.Sprintf("line %d", i) }
+
+	for _, tc := range []struct {
+		name   string
+		logDir bool
+	}{
+		{name: "spilled to disk", logDir: true},
+		{name: "memory only"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := JobManagerOptions{LogMemLines: mem}
+			if tc.logDir {
+				opts.LogDir = t.TempDir()
+			}
+			m, err := NewJobManager(nil, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			job := m.NewJob(TransferRequest{}, &TransferPlan{})
+			for i := 0; i < total; i += 2 {
+				job.appendLog(line(i) + "\n" + line(i+1)) // 带换行的拆成两行
+			}
+
+			snap := job.Snapshot(-1)
+			if snap.LogTotal != total || len(snap.LogLines) != mem || snap.LogLines[0] != line(total-mem) {
+				t.Fatalf("ring: total = %d, %d lines in memory, first %q", snap.LogTotal, len(snap.LogLines), snap.LogLines[0])
+			}
+
+			for _, pc := range []struct {
+				offset, limit int
+				from, n       int // 期望的第一行行号和行数（只存内存时更早的行已经丢了）
+				memFrom, memN int
+			}{
+				{offset: 0, limit: 10, from: 0, n: 10, memFrom: total - mem, memN: 10},
+				{offset: 1020, limit: 10, from: 1020, n: 10, memFrom: total - mem, memN: 10}, // 跨过索引点
+				{offset: 2050, limit: 5, from: 2050, n: 5, memFrom: total - mem, memN: 5},
+				{offset: total - mem - 3, limit: 6, from: total - mem - 3, n: 6, memFrom: total - mem, memN: 6}, // 跨内存窗口边界
+				{offset: total - 4, limit: 10, from: total - 4, n: 4, memFrom: total - 4, memN: 4},
+				{offset: total + 5, limit: 10, from: total, n: 0, memFrom: total, memN: 0},
+			} {
+				from, n := pc.from, pc.n
+				if !tc.logDir {
+					from, n = pc.memFrom, pc.memN
+				}
+				page, err := job.ReadLog(pc.offset, pc.limit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if page.Total != total || page.Offset != from || len(page.Lines) != n || page.NextOffset != from+n {
+					t.Fatalf("ReadLog(%d, %d) = offset %d, %d lines, next %d, total %d; want offset %d, %d lines",
+						pc.offset, pc.limit, page.Offset, len(page.Lines), page.NextOffset, page.Total, from, n)
+				}
+				for k, l := range page.Lines {
+					if l != line(from+k) {
+						t.Fatalf("ReadLog(%d, %d) line %d = %q, want %q", pc.offset, pc.limit, k, l, line(from+k))
+					}
+				}
+			}
+
+			// 按 NextOffset 一页页读完：磁盘上是完整日志，只存内存时是最后 mem 行
+			var got []string
+			for off := 0; ; {
+				page, err := job.ReadLog(off, 700)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(page.Lines) == 0 {
+					break
+				}
+				got = append(got, page.Lines...)
+				off = page.NextOffset
+			}
+			want := total
+			if !tc.logDir {
+				want = mem
+			}
+			if len(got) != want || got[len(got)-1] != line(total-1) {
+				t.Fatalf("paged read: %d lines, want %d", len(got), want)
+			}
+
+			rc, err := job.OpenLog()
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := strings.Count(string(data), "\n"); n != want {
+				t.Fatalf("OpenLog: %d lines, want %d", n, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ===== 任务列表：摘要 + 过滤/排序/分页 =====
+
+const (
+	defaultJobQueryLimit = 100
+	maxJobQueryLimit     = 1000
+	maxSummaryLogTail    = 200
+)
+
+// JobSummary：列表用的轻量版 Job，不带完整日志
+type JobSummary struct {
+	ID        string         `json:"id"`
+	ParentID  string         `json:"parentId,omitempty"`
+	Task      string         `json:"task,omitempty"`
+	Schedule  string         `json:"schedule,omitempty"`
+	Pipeline  string         `json:"pipeline,omitempty"`
+	FanOut    string         `json:"fanOut,omitempty"`
+	Status    JobStatus      `json:"status"`
+	CreatedAt time.Time      `json:"createdAt"`
+	StartedAt time.Time      `json:"startedAt"`
+	EndedAt   time.Time      `json:"endedAt"`
+	Plan      TransferPlan   `json:"plan"`
+	Priority  int            `json:"priority"`
+	Progress  Progress       `json:"progress"`
+	Attempts  int            `json:"attempts"`
+	LogTotal  int            `json:"logTotal"`
+	Stats     *TransferStats `json:"stats,omitempty"`
+	LogLines  []string       `json:"logLines,omitempty"` // 最后 LogTail 行，默认不带
+
+	FanOutResult *FanOutResult `json:"fanOutResult,omitempty"` // 扇出父任务的汇总
+}
+
+// Summary 在锁内生成摘要；logTail > 0 时带上最后 logTail 行日志
+func (j *Job) Summary(logTail int) JobSummary {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	s := JobSummary{
+		ID:        j.ID,
+		ParentID:  j.ParentID,
+		Task:      j.Task,
+		Schedule:  j.Schedule,
+		Pipeline:  j.Pipeline,
+		FanOut:    j.FanOut,
+		Status:    j.Status,
+		CreatedAt: j.CreatedAt,
+		StartedAt: j.StartedAt,
+		EndedAt:   j.EndedAt,
+		Plan:      j.Plan,
+		Priority:  j.Request.Priority,
+		Progress:  j.Progress,
+		Attempts:  len(j.Attempts),
+		LogTotal:  j.LogTotal,
+		Stats:     j.Stats,
+
+		FanOutResult: j.FanOutResult.clone(),
+	}
+	if logTail > 0 {
+		lines := j.LogLines
+		if len(lines) > logTail {
+			lines = lines[len(lines)-logTail:]
+		}
+		s.LogLines = append([]string(nil), lines...)
+	}
+	return s
+}
+
+// JobQuery：ListJobs 的过滤条件，零值表示不过滤
+type JobQuery struct {
+	Statuses []JobStatus
+	Host     string    // source/dest/execHost 任意一个匹配
+	Task     string    // 由哪个保存的 task 启动
+	Schedule string    // 由哪个定时触发
+	Pipeline string    // 属于哪次流水线运行
+	FanOut   string    // 属于哪个扇出父任务
+	Since    time.Time // CreatedAt >= Since
+	Until    time.Time // CreatedAt < Until
+	Search   string    // 在 ID、源/目标路径里做不区分大小写的子串匹配
+	Asc      bool      // 默认最新的在前
+	Limit    int       // 0 = 100，最多 1000
+	Cursor   string    // 上一页返回的 NextCursor
+	LogTail  int       // 每个摘要带的日志行数，最多 200
+}
+
+// JobPage：一页摘要；NextCursor 为空表示没有更多了
+type JobPage struct {
+	Jobs       []JobSummary `json:"jobs"`
+	NextCursor string       `json:"nextCursor,omitempty"`
+}
+
+var ErrBadCursor = errors.New("invalid cursor")
+
+// jobLess：m.order 的排序规则（CreatedAt，再按 ID）
+func jobLess(a, b *Job) bool {
+	if a.CreatedAt.Equal(b.CreatedAt) {
+		return a.ID < b.ID
+	}
+	return a.CreatedAt.Before(b.CreatedAt)
+}
+
+// insertOrderLocked 把 job 插到 m.order 的正确位置；调用方需持有 m.mu 写锁。
+// 新任务的 CreatedAt 基本都是最大的，所以通常就是 append。
+func (m *JobManager) insertOrderLocked(job *Job) {
+	i := len(m.order)
+	for i > 0 && jobLess(job, m.order[i-1]) {
+		i--
+	}
+	m.order = append(m.order, nil)
+	copy(m.order[i+1:], m.order[i:])
+	m.order[i] = job
+}
+
+// QueryJobs 按 CreatedAt 顺序遍历 m.order，跳过不匹配的，凑够一页就停
+func (m *JobManager) QueryJobs(q JobQuery) (*JobPage, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultJobQueryLimit
+	}
+	limit = min(limit, maxJobQueryLimit)
+	logTail := min(q.LogTail, maxSummaryLogTail)
+
+	var cursor *Job
+	if q.Cursor != "" {
+		c, err := parseJobCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		cursor = c
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// 起点：cursor 之后；时间范围能直接二分跳过的部分也跳过
+	n := len(m.order)
+	i, step := n-1, -1
+	if q.Asc {
+		i, step = 0, 1
+		if cursor != nil {
+			i = sort.Search(n, func(k int) bool { return jobLess(cursor, m.order[k]) })
+		}
+		if !q.Since.IsZero() {
+			i = max(i, sort.Search(n, func(k int) bool { return !m.order[k].CreatedAt.Before(q.Since) }))
+		}
+	} else {
+		if cursor != nil {
+			i = sort.Search(n, func(k int) bool { return !jobLess(m.order[k], cursor) }) - 1
+		}
+		if !q.Until.IsZero() {
+			i = min(i, sort.Search(n, func(k int) bool { return !m.order[k].CreatedAt.Before(q.Until) })-1)
+		}
+	}
+
+	page := &JobPage{Jobs: []JobSummary{}}
+	var last *Job
+	for ; i >= 0 && i < n; i += step {
+		j := m.order[i]
+		if q.Asc && !q.Until.IsZero() && !j.CreatedAt.Before(q.Until) {
+			break
+		}
+		if !q.Asc && !q.Since.IsZero() && j.CreatedAt.Before(q.Since) {
+			break
+		}
+		s := j.Summary(0)
+		if !q.match(&s) {
+			continue
+		}
+		if len(page.Jobs) == limit {
+			page.NextCursor = jobCursor(last)
+			break
+		}
+		if logTail > 0 {
+			s = j.Summary(logTail)
+		}
+		page.Jobs = append(page.Jobs, s)
+		last = j
+	}
+	return page, nil
+}
+
+func (q *JobQuery) match(s *JobSummary) bool {
+	if len(q.Statuses) > 0 {
+		ok := false
+		for _, st := range q.Statuses {
+			if s.Status == st {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if q.Host != "" && s.Plan.Source.HostName != q.Host && s.Plan.Dest.HostName != q.Host && s.Plan.ExecHost != q.Host {
+		return false
+	}
+	if q.Task != "" && s.Task != q.Task {
+		return false
+	}
+	if q.Schedule != "" && s.Schedule != q.Schedule {
+		return false
+	}
+	if q.Pipeline != "" && s.Pipeline != q.Pipeline {
+		return false
+	}
+	if q.FanOut != "" && s.FanOut != q.FanOut {
+		return false
+	}
+	if q.Search != "" {
+		needle := strings.ToLower(q.Search)
+		if !strings.Contains(strings.ToLower(s.ID), needle) &&
+			!strings.Contains(strings.ToLower(s.Plan.Source.Path), needle) &&
+			!strings.Contains(strings.ToLower(s.Plan.Dest.Path), needle) {
+			return false
+		}
+	}
+	return true
+}
+
+// 游标：<CreatedAt 纳秒>.<ID>
+func jobCursor(j *Job) string {
+	return strconv.FormatInt(j.CreatedAt.UnixNano(), 10) + "." + j.ID
+}
+
+func parseJobCursor(s string) (*Job, error) {
+	ns, id, ok := strings.Cut(s, ".")
+	if !ok || id == "" {
+		return nil, ErrBadCursor
+	}
+	n, err := strconv.ParseInt(ns, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadCursor, err)
+	}
+	return &Job{ID: id, CreatedAt: time.Unix(0, n)}, nil
+}
@@ -0,0 +1,96 @@
+package app
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestQueryJobsCursor(t *testing.T) {
+	m, err := NewJobManager(nil, JobManagerOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var all []*Job
+	for i := 0; i < 25; i++ {
+		host := "h1"
+		if i%2 == 1 {
+			host = "h2"
+		}
+		all = append(all, m.NewJob(TransferRequest{}, &TransferPlan{Source: Endpoint{HostName: host}, Dest: Endpoint{HostName: "local"}}))
+	}
+	// CreatedAt 可能相同，期望顺序按 jobLess 算
+	sort.Slice(all, func(a, b int) bool { return jobLess(all[a], all[b]) })
+	var h1 []string
+	for _, j := range all {
+		if j.Plan.Source.HostName == "h1" {
+			h1 = append(h1, j.ID)
+		}
+	}
+
+	for _, tc := range []struct {
+		name  string
+		asc   bool
+		limit int
+		pages int
+	}{
+		{name: "newest first", limit: 5, pages: 3},
+		{name: "oldest first", asc: true, limit: 4, pages: 4},
+		{name: "exact last page", limit: 13, pages: 1}, // 正好凑满一页：没有更多了，不给 cursor
+		{name: "one per page", asc: true, limit: 1, pages: 13},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			want := append([]string(nil), h1...)
+			if !tc.asc {
+				for l, r := 0, len(want)-1; l < r; l, r = l+1, r-1 {
+					want[l], want[r] = want[r], want[l]
+				}
+			}
+			var got []string
+			q := JobQuery{Host: "h1", Asc: tc.asc, Limit: tc.limit}
+			for pages := 1; ; pages++ {
+				page, err := m.QueryJobs(q)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, s := range page.Jobs {
+					got = append(got, s.ID)
+				}
+				if page.NextCursor == "" {
+					if pages != tc.pages {
+						t.Fatalf("%d pages, want %d", pages, tc.pages)
+					}
+					break
+				}
+				if len(page.Jobs) != tc.limit || pages > tc.pages {
+					t.Fatalf("page %d: %d jobs, cursor %q", pages, len(page.Jobs), page.NextCursor)
+				}
+				q.Cursor = page.NextCursor
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("paged IDs = %v\nwant %v", got, want)
+			}
+		})
+	}
+
+	// 游标之后再插进来的更新任务不影响往旧的方向翻页
+	first, err := m.QueryJobs(JobQuery{Host: "h1", Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.NewJob(TransferRequest{}, &TransferPlan{Source: Endpoint{HostName: "h1"}})
+	next, err := m.QueryJobs(JobQuery{Host: "h1", Limit: 5, Cursor: first.NextCursor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.Jobs[0].ID != h1[len(h1)-6] {
+		t.Fatalf("page after new job starts at %s, want %s", next.Jobs[0].ID, h1[len(h1)-6])
+	}
+
+	for _, c := range []string{"nodot", "abc.id", "123."} {
+		if _, err := m.QueryJobs(JobQuery{Cursor: c}); !errors.Is(err, ErrBadCursor) {
+			t.Fatalf("cursor %q: %v", c, err)
+		}
+	}
+}
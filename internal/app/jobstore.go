@@ -0,0 +1,166 @@
+package app
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// JobStore：任务历史的持久化后端（nil 表示只存内存）
+type JobStore interface {
+	// Save 写入 job 的当前快照（同一个 ID 多次 Save，以最后一次为准）
+	Save(job *Job) error
+	// Delete 删除某个 job 的记录
+	Delete(id string) error
+	// LoadAll 读出所有 job（启动时调用）
+	LoadAll() ([]*Job, error)
+}
+
+// jsonlJobStore：JSON-lines 文件，每次 Save 追加一行快照，启动时压缩
+type jsonlJobStore struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+}
+
+type jobStoreRecord struct {
+	Job     *Job   `json:"job,omitempty"`
+	Deleted string `json:"deleted,omitempty"` // 被删除的 job ID
+}
+
+// OpenJSONLJobStore 打开（或创建）dataDir/jobs.jsonl
+func OpenJSONLJobStore(dataDir string) (JobStore, error) {
+	if err := os.MkdirAll(dataDir, 0o755); err != nil {
+		return nil, fmt.Errorf("mkdir data dir: %w", err)
+	}
+	s := &jsonlJobStore{path: filepath.Join(dataDir, "jobs.jsonl")}
+
+	// 先压缩一次，避免文件无限增长
+	jobs, err := s.readAll()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.rewrite(jobs); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open job store: %w", err)
+	}
+	s.f = f
+	return s, nil
+}
+
+func (s *jsonlJobStore) Save(job *Job) error {
+	job.mu.Lock()
+	b, err := json.Marshal(jobStoreRecord{Job: job})
+	job.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("marshal job %s: %w", job.ID, err)
+	}
+	return s.appendLine(b)
+}
+
+func (s *jsonlJobStore) Delete(id string) error {
+	b, err := json.Marshal(jobStoreRecord{Deleted: id})
+	if err != nil {
+		return err
+	}
+	return s.appendLine(b)
+}
+
+func (s *jsonlJobStore) LoadAll() ([]*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.readAll()
+}
+
+func (s *jsonlJobStore) appendLine(b []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.f.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("write job store: %w", err)
+	}
+	return nil
+}
+
+// readAll：按行回放，后写的覆盖先写的；坏行（比如进程被杀时写了半行）直接跳过
+func (s *jsonlJobStore) readAll() ([]*Job, error) {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open job store: %w", err)
+	}
+	defer f.Close()
+
+	byID := make(map[string]*Job)
+	var order []string
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 64<<20)
+	for sc.Scan() {
+		var rec jobStoreRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			continue
+		}
+		if rec.Deleted != "" {
+			delete(byID, rec.Deleted)
+			continue
+		}
+		if rec.Job == nil || rec.Job.ID == "" {
+			continue
+		}
+		if _, ok := byID[rec.Job.ID]; !ok {
+			order = append(order, rec.Job.ID)
+		}
+		byID[rec.Job.ID] = rec.Job
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read job store: %w", err)
+	}
+
+	out := make([]*Job, 0, len(byID))
+	for _, id := range order {
+		if j, ok := byID[id]; ok {
+			out = append(out, j)
+		}
+	}
+	return out, nil
+}
+
+// rewrite：写临时文件再 rename，保证压缩过程中崩溃不丢历史
+func (s *jsonlJobStore) rewrite(jobs []*Job) error {
+	tmp := s.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("compact job store: %w", err)
+	}
+	bw := bufio.NewWriter(f)
+	enc := json.NewEncoder(bw)
+	for _, j := range jobs {
+		if err := enc.Encode(jobStoreRecord{Job: j}); err != nil {
+			_ = f.Close()
+			_ = os.Remove(tmp)
+			return fmt.Errorf("compact job store: %w", err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return fmt.Errorf("compact job store: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("compact job store: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("compact job store: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package app
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJSONLJobStore(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "jobs.jsonl")
+	now := time.Now()
+
+	store, err := OpenJSONLJobStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &Job{ID: "a", Status: JobPending, CreatedAt: now}
+	b := &Job{ID: "b", Status: JobOK, CreatedAt: now}
+	for _, step := range []func() error{
+		func() error { return store.Save(a) },
+		func() error { a.Status = JobRunning; return store.Save(a) },
+		func() error { return store.Save(b) },
+		func() error { return store.Delete("b") },
+	} {
+		if err := step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.(*jsonlJobStore).f.Close()
+
+	// 进程被杀时可能留下半行
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"job":{"id":"c","sta`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	// 重新打开时压缩：只剩 a 的最后一次快照
+	store, err = OpenJSONLJobStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := store.LoadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || jobs[0].ID != "a" || jobs[0].Status != JobRunning {
+		t.Fatalf("loaded %d jobs: %+v", len(jobs), jobs)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(raw, []byte("\n")); n != 1 {
+		t.Fatalf("compacted file has %d lines:\n%s", n, raw)
+	}
+
+	// 上次退出时还在跑的任务加载后标记为 interrupted，并写回 store
+	reg, err := NewHostRegistry(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jm, err := NewJobManager(reg, JobManagerOptions{Store: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, ok := jm.GetJob("a")
+	if !ok || job.Status != JobInterrupted {
+		t.Fatalf("job a = %+v", job)
+	}
+	store.(*jsonlJobStore).f.Close()
+
+	store, err = OpenJSONLJobStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.(*jsonlJobStore).f.Close()
+	if jobs, err = store.LoadAll(); err != nil || len(jobs) != 1 || jobs[0].Status != JobInterrupted {
+		t.Fatalf("after reload: %v %+v", err, jobs)
+	}
+}
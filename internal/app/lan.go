@@ -0,0 +1,257 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// ===== 每一跳连 LAN 地址还是 WAN 地址：规划时探测决定 =====
+//
+// 配了 lanHost/lanPort 的主机，先从发起方（本机，或者执行端那台远程）TCP connect 一下 LAN 地址：
+// 连得上就走 LAN（并用更快的加密算法），连不上自动退回 WAN 地址。
+// Profile=WAN 时不探测，全部走 WAN。结果按 (发起方, 目标) 缓存一会儿，记在 plan.Hops 里，
+// 执行时直接用，不再各自判断。
+
+const (
+	lanProbeTimeout  = 2 * time.Second
+	lanProbeCacheTTL = 2 * time.Minute
+)
+
+// HopDial：一跳实际连的地址
+type HopDial struct {
+	From      string  `json:"from"` // "local" 或发起连接的主机名
+	To        string  `json:"to"`
+	Host      string  `json:"host"`
+	Port      int     `json:"port"`
+	Lan       bool    `json:"lan"`
+	LatencyMs float64 `json:"latencyMs,omitempty"` // LAN 地址的 TCP 连接耗时
+	Reason    string  `json:"reason,omitempty"`
+}
+
+func (h HopDial) target() DialTarget { return DialTarget{Host: h.Host, Port: h.Port} }
+
+// preferLan：Profile 明确选 WAN 时不尝试 LAN 地址
+func preferLan(req TransferRequest) bool {
+	return !strings.EqualFold(req.Options.Profile, "WAN")
+}
+
+type lanCache struct {
+	mu      sync.Mutex
+	entries map[string]lanCacheEntry
+}
+
+type lanCacheEntry struct {
+	reach ReachProbe
+	at    time.Time
+}
+
+func (c *lanCache) get(key string, now time.Time) (ReachProbe, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || now.Sub(e.at) > lanProbeCacheTTL {
+		return ReachProbe{}, false
+	}
+	return e.reach, true
+}
+
+func (c *lanCache) put(key string, r ReachProbe, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]lanCacheEntry)
+	}
+	c.entries[key] = lanCacheEntry{reach: r, at: now}
+}
+
+// decideHop：from 为 nil 表示从本机发起
+func (a *App) decideHop(ctx context.Context, from, to *Host, useLan bool) HopDial {
+	hop := HopDial{From: "local", To: to.Config.Name}
+	if from != nil {
+		hop.From = from.Config.Name
+	}
+	wan := dialFor(&to.Config, false)
+	hop.Host, hop.Port = wan.Host, wan.Port
+
+	c := &to.Config
+	switch {
+	case !useLan:
+		hop.Reason = "WAN profile"
+		return hop
+	case c.LanHost == "" || c.LanPort <= 0:
+		hop.Reason = "no LAN address configured"
+		return hop
+	}
+
+	lan := DialTarget{Host: c.LanHost, Port: c.LanPort}
+	r := a.probeLan(ctx, from, lan)
+	if !r.OK {
+		hop.Reason = fmt.Sprintf("LAN %s unreachable (%s), using WAN", r.Target, r.Error)
+		return hop
+	}
+	hop.Host, hop.Port, hop.Lan, hop.LatencyMs = lan.Host, lan.Port, true, r.LatencyMs
+	hop.Reason = "LAN " + r.Target + " reachable in " + fmtMs(r.LatencyMs)
+	return hop
+}
+
+// probeLan：本机直接 TCP connect；远程上用和拓扑探测一样的脚本。探测本身失败（连不上发起方、ctx 到期）不缓存
+func (a *App) probeLan(ctx context.Context, from *Host, lan DialTarget) ReachProbe {
+	target := net.JoinHostPort(lan.Host, strconv.Itoa(lan.Port))
+	key := "local"
+	if from != nil {
+		key = hostCacheKey(from)
+	}
+	key += "\xff" + target
+	if r, ok := a.lan.get(key, time.Now()); ok {
+		return r
+	}
+
+	var r ReachProbe
+	if from == nil || from.IsLocal {
+		start := time.Now()
+		d := net.Dialer{Timeout: lanProbeTimeout}
+		conn, err := d.DialContext(ctx, "tcp", target)
+		if err != nil && ctx.Err() != nil {
+			return ReachProbe{Target: target, Error: "probe cancelled: " + ctx.Err().Error()}
+		}
+		if err != nil {
+			r = ReachProbe{Error: err.Error()}
+		} else {
+			r = ReachProbe{OK: true, LatencyMs: msSince(start)}
+			_ = conn.Close()
+		}
+	} else {
+		out, err := runSSHCtx(ctx, from, reachProbeScript(lan, int(lanProbeTimeout/time.Second)))
+		if err != nil && !strings.Contains(out, "REACH=") {
+			return ReachProbe{Target: target, Error: "probe from " + from.Config.Name + " failed: " + err.Error()}
+		}
+		_, r = parseTopologyProbe(out)
+	}
+	r.Target = target
+	a.lan.put(key, r, time.Now())
+	return r
+}
+
+// planHops：按执行方式列出要建立的每一跳并决定地址
+func (a *App) planHops(ctx context.Context, plan *TransferPlan, srcHost, dstHost *Host, useLan bool) {
+	type pair struct{ from, to *Host }
+	var pairs []pair
+	switch plan.Mode {
+	case ExecLocal:
+		switch {
+		case !srcHost.IsLocal:
+			pairs = append(pairs, pair{nil, srcHost})
+		case !dstHost.IsLocal:
+			pairs = append(pairs, pair{nil, dstHost})
+		}
+	case ExecTwoStepLocal, ExecRelayStream:
+		pairs = append(pairs, pair{nil, srcHost}, pair{nil, dstHost})
+	case ExecOnSource:
+		pairs = append(pairs, pair{nil, srcHost}, pair{srcHost, dstHost})
+	case ExecOnDest:
+		pairs = append(pairs, pair{nil, dstHost}, pair{dstHost, srcHost})
+	case ExecViaHost:
+		execHost, ok := a.Hosts.Get(plan.ExecHost)
+		if !ok {
+			return
+		}
+		pairs = append(pairs, pair{nil, execHost}, pair{execHost, srcHost}, pair{execHost, dstHost})
+	}
+
+	plan.Hops = make([]HopDial, len(pairs))
+	var wg sync.WaitGroup
+	for i, p := range pairs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			plan.Hops[i] = a.decideHop(ctx, p.from, p.to, useLan)
+		}()
+	}
+	wg.Wait()
+}
+
+// dial：执行时取某一跳的地址；plan 里没有（比如升级前的历史任务）就按老规则走 WAN
+func (p *TransferPlan) dial(from string, to *Host) (DialTarget, bool) {
+	for _, h := range p.Hops {
+		if h.From == from && h.To == to.Config.Name {
+			return h.target(), h.Lan
+		}
+	}
+	return dialFor(&to.Config, false), false
+}
+
+// dialHop：从本机连 h。plan 里这一跳走 LAN 但连不上（探测之后网络变了）时改走 WAN 地址再连一次，
+// 并把 plan 里这一跳改成 WAN，后面的重试直接用 WAN
+func dialHop(ctx context.Context, job *Job, h *Host) (*ssh.Client, DialTarget, error) {
+	job.mu.Lock()
+	d, lan := job.Plan.dial("local", h)
+	job.mu.Unlock()
+	cli, err := sshDialContext(ctx, &h.Config, d, lan)
+	if err == nil || !lan || ctx.Err() != nil {
+		return cli, d, err
+	}
+	job.appendLog(fmt.Sprintf("[net] local -> %s: LAN %s failed (%v), retrying on WAN",
+		h.Config.Name, net.JoinHostPort(d.Host, strconv.Itoa(d.Port)), err))
+	job.useWan("local", h, "LAN dial failed, fell back to WAN")
+	d = dialFor(&h.Config, false)
+	cli, err = sshDialContext(ctx, &h.Config, d, false)
+	return cli, d, err
+}
+
+// useWan：把 plan 里 from -> to 这一跳改成 WAN 地址，返回原来是不是 LAN。
+// Hops 整个换成新切片，已经拿到旧 plan 拷贝的地方不受影响
+func (j *Job) useWan(from string, to *Host, reason string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for i, h := range j.Plan.Hops {
+		if h.From != from || h.To != to.Config.Name || !h.Lan {
+			continue
+		}
+		wan := dialFor(&to.Config, false)
+		hops := append([]HopDial(nil), j.Plan.Hops...)
+		hops[i] = HopDial{From: from, To: h.To, Host: wan.Host, Port: wan.Port, Reason: reason}
+		j.Plan.Hops = hops
+		j.appendLogLocked(describeHop(hops[i]))
+		return true
+	}
+	return false
+}
+
+// innerHopsToWan：执行端上的 rsync 以 255 退出（它调的 ssh 连不上对端）时，把执行端发起的 LAN 跳都改走 WAN。
+// 返回有没有改动；没有 LAN 跳可改时按普通失败处理
+func (m *JobManager) innerHopsToWan(job *Job, err error) bool {
+	var exitErr *ssh.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitStatus() != 255 {
+		return false
+	}
+	job.mu.Lock()
+	hops := job.Plan.Hops
+	job.mu.Unlock()
+	changed := false
+	for _, h := range hops {
+		if h.From == "local" || !h.Lan {
+			continue
+		}
+		if to, ok := m.hosts.Get(h.To); ok && job.useWan(h.From, to, "LAN unreachable from "+h.From+" (ssh exit 255), fell back to WAN") {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// describeHop：写进任务日志的一行
+func describeHop(h HopDial) string {
+	kind := "WAN"
+	if h.Lan {
+		kind = "LAN"
+	}
+	return fmt.Sprintf("[net] %s -> %s: %s %s (%s)", h.From, h.To, kind, net.JoinHostPort(h.Host, strconv.Itoa(h.Port)), h.Reason)
+}
@@ -0,0 +1,82 @@
+package app
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDecideHop(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lanPort := ln.Addr().(*net.TCPAddr).Port
+
+	// 拿一个肯定没人监听的端口
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+
+	reg, err := NewHostRegistry([]HostConfig{
+		{Name: "office", Host: "wan.example", Port: 2222, LanHost: "127.0.0.1", LanPort: lanPort},
+		{Name: "away", Host: "wan2.example", LanHost: "127.0.0.1", LanPort: deadPort},
+		{Name: "plain", Host: "wan3.example"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &App{Hosts: reg}
+	get := func(name string) *Host {
+		h, _ := reg.Get(name)
+		return h
+	}
+
+	hop := a.decideHop(context.Background(), nil, get("office"), true)
+	if !hop.Lan || hop.Host != "127.0.0.1" || hop.Port != lanPort {
+		t.Fatalf("office: %+v", hop)
+	}
+	hop = a.decideHop(context.Background(), nil, get("office"), false)
+	if hop.Lan || hop.Host != "wan.example" || hop.Port != 2222 {
+		t.Fatalf("office, WAN profile: %+v", hop)
+	}
+	hop = a.decideHop(context.Background(), nil, get("away"), true)
+	if hop.Lan || hop.Host != "wan2.example" || hop.Port != 22 || !strings.Contains(hop.Reason, "unreachable") {
+		t.Fatalf("away: %+v", hop)
+	}
+	if hop = a.decideHop(context.Background(), nil, get("plain"), true); hop.Lan {
+		t.Fatalf("plain: %+v", hop)
+	}
+
+	// 结果有缓存：LAN 地址关掉后，缓存期内仍然走 LAN
+	ln.Close()
+	if hop = a.decideHop(context.Background(), nil, get("office"), true); !hop.Lan {
+		t.Fatalf("office, cached: %+v", hop)
+	}
+
+	plan := &TransferPlan{Hops: []HopDial{hop}}
+	if d, lan := plan.dial("local", get("office")); !lan || d.Port != lanPort {
+		t.Fatalf("dial office = %+v %v", d, lan)
+	}
+	if d, lan := plan.dial("local", get("away")); lan || net.JoinHostPort(d.Host, strconv.Itoa(d.Port)) != "wan2.example:22" {
+		t.Fatalf("dial away = %+v %v", d, lan)
+	}
+
+	// 执行时 LAN 连不上：这一跳改走 WAN，旧的 plan 拷贝不受影响
+	job := &Job{Plan: TransferPlan{Hops: []HopDial{hop}}}
+	before := job.Plan
+	if !job.useWan("local", get("office"), "test") || job.useWan("local", get("office"), "test") {
+		t.Fatal("useWan should flip the LAN hop exactly once")
+	}
+	if d, lan := job.Plan.dial("local", get("office")); lan || d.Host != "wan.example" || d.Port != 2222 {
+		t.Fatalf("after fallback dial = %+v %v", d, lan)
+	}
+	if !before.Hops[0].Lan {
+		t.Fatal("earlier plan copy was modified")
+	}
+}
@@ -0,0 +1,235 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ===== Prometheus 指标：手写文本格式（text/plain; version=0.0.4），不引客户端库 =====
+//
+// 计数器和直方图都在进程内累计，重启归零；队列深度等 gauge 在抓取时现算。
+
+var (
+	durationBuckets    = []float64{1, 5, 15, 30, 60, 300, 900, 1800, 3600, 7200, 21600}
+	latencyBuckets     = []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	uploadBytesBuckets = []float64{1e3, 1e4, 1e5, 1e6, 1e7, 1e8, 1e9}
+)
+
+type metricsRegistry struct {
+	mu         sync.Mutex
+	counters   []*counterVec
+	histograms []*histogramVec
+}
+
+// counterVec：带标签的计数器；values 的 key 是标签值用 \xff 拼起来
+type counterVec struct {
+	name, help string
+	labels     []string
+	values     map[string]float64
+}
+
+type histogramVec struct {
+	name, help string
+	labels     []string
+	buckets    []float64
+	series     map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64 // 和 buckets 一一对应（不累加，输出时再累加）
+	count  uint64
+	sum    float64
+}
+
+func (r *metricsRegistry) counter(name, help string, labels ...string) *counterVec {
+	c := &counterVec{name: name, help: help, labels: labels, values: make(map[string]float64)}
+	r.counters = append(r.counters, c)
+	return c
+}
+
+func (r *metricsRegistry) histogram(name, help string, buckets []float64, labels ...string) *histogramVec {
+	h := &histogramVec{name: name, help: help, labels: labels, buckets: buckets, series: make(map[string]*histogram)}
+	r.histograms = append(r.histograms, h)
+	return h
+}
+
+func (r *metricsRegistry) add(c *counterVec, v float64, labelValues ...string) {
+	r.mu.Lock()
+	c.values[strings.Join(labelValues, "\xff")] += v
+	r.mu.Unlock()
+}
+
+func (r *metricsRegistry) observe(h *histogramVec, v float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := h.series[key]
+	if s == nil {
+		s = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	for i, b := range h.buckets {
+		if v <= b {
+			s.counts[i]++
+			break
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+var (
+	metrics = &metricsRegistry{}
+
+	mJobsTotal       = metrics.counter("rsyncgui_jobs_total", "Finished jobs by final status and execution mode.", "status", "mode")
+	mTransferBytes   = metrics.counter("rsyncgui_transfer_bytes_total", "File bytes moved by finished jobs.", "mode")
+	mWireBytes       = metrics.counter("rsyncgui_transfer_wire_bytes_total", "Bytes on the wire reported by transfer statistics.", "mode", "direction")
+	mJobDuration     = metrics.histogram("rsyncgui_job_duration_seconds", "Job run time from start to end.", durationBuckets, "status", "mode")
+	mSSHDial         = metrics.histogram("rsyncgui_ssh_dial_duration_seconds", "SSH dial and handshake latency.", latencyBuckets, "host")
+	mSSHDialFailures = metrics.counter("rsyncgui_ssh_dial_failures_total", "Failed SSH dials.", "host")
+	mFSList          = metrics.histogram("rsyncgui_fs_list_duration_seconds", "Directory listing latency.", latencyBuckets, "host")
+	mFSListFailures  = metrics.counter("rsyncgui_fs_list_failures_total", "Failed directory listings.", "host")
+	mUploadBytes     = metrics.histogram("rsyncgui_upload_size_bytes", "Sizes of files uploaded through the UI.", uploadBytesBuckets, "host")
+)
+
+// observeSSHDial：记录一次 SSH 连接的耗时；失败另计一次
+func observeSSHDial(host string, start time.Time, err error) {
+	if err != nil {
+		metrics.add(mSSHDialFailures, 1, host)
+		return
+	}
+	metrics.observe(mSSHDial, time.Since(start).Seconds(), host)
+}
+
+// observeJobMetrics 注册到 JobManager.OnJobFinished
+func observeJobMetrics(job *Job) {
+	job.mu.Lock()
+	status, mode := string(job.Status), string(job.Plan.Mode)
+	started, ended := job.StartedAt, job.EndedAt
+	bytesDone := job.Progress.BytesDone
+	stats := job.Stats
+	job.mu.Unlock()
+
+	metrics.add(mJobsTotal, 1, status, mode)
+	if !started.IsZero() && !ended.IsZero() {
+		metrics.observe(mJobDuration, ended.Sub(started).Seconds(), status, mode)
+	}
+	if stats != nil && stats.TransferredFileSize > 0 {
+		bytesDone = stats.TransferredFileSize
+	}
+	metrics.add(mTransferBytes, float64(bytesDone), mode)
+	if stats != nil {
+		metrics.add(mWireBytes, float64(stats.BytesSent), mode, "sent")
+		metrics.add(mWireBytes, float64(stats.BytesReceived), mode, "received")
+	}
+}
+
+// WriteMetrics 按 Prometheus 文本格式输出所有指标
+func (a *App) WriteMetrics(w io.Writer) error {
+	var b strings.Builder
+
+	qs := a.JobManager.QueueStats()
+	writeGauge(&b, "rsyncgui_queue_depth", "Jobs waiting in the queue.", float64(qs.Queued))
+	writeGauge(&b, "rsyncgui_jobs_running", "Jobs currently running.", float64(qs.Running))
+	writeGauge(&b, "rsyncgui_jobs_max_running", "Global concurrency limit.", float64(qs.MaxRunning))
+	b.WriteString("# HELP rsyncgui_host_jobs_running Running jobs holding a slot on each host.\n")
+	b.WriteString("# TYPE rsyncgui_host_jobs_running gauge\n")
+	hosts := make([]string, 0, len(qs.HostRunning))
+	for h := range qs.HostRunning {
+		hosts = append(hosts, h)
+	}
+	sort.Strings(hosts)
+	for _, h := range hosts {
+		fmt.Fprintf(&b, "rsyncgui_host_jobs_running%s %d\n", formatLabels([]string{"host"}, []string{h}, "", ""), qs.HostRunning[h])
+	}
+
+	metrics.writeTo(&b)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (r *metricsRegistry) writeTo(b *strings.Builder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, c := range r.counters {
+		fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+		for _, key := range sortedKeys(c.values) {
+			fmt.Fprintf(b, "%s%s %s\n", c.name, formatLabels(c.labels, splitLabelKey(key), "", ""), formatFloat(c.values[key]))
+		}
+	}
+	for _, h := range r.histograms {
+		fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+		for _, key := range sortedKeys(h.series) {
+			s := h.series[key]
+			values := splitLabelKey(key)
+			var cum uint64
+			for i, le := range h.buckets {
+				cum += s.counts[i]
+				fmt.Fprintf(b, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, values, "le", formatFloat(le)), cum)
+			}
+			fmt.Fprintf(b, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, values, "le", "+Inf"), s.count)
+			fmt.Fprintf(b, "%s_sum%s %s\n", h.name, formatLabels(h.labels, values, "", ""), formatFloat(s.sum))
+			fmt.Fprintf(b, "%s_count%s %d\n", h.name, formatLabels(h.labels, values, "", ""), s.count)
+		}
+	}
+}
+
+func writeGauge(b *strings.Builder, name, help string, v float64) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n", name, help, name, name, formatFloat(v))
+}
+
+// formatLabels：{a="x",b="y"}；extraName 非空时追加一个（直方图的 le）
+func formatLabels(names, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, n := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		v := ""
+		if i < len(values) {
+			v = values[i]
+		}
+		fmt.Fprintf(&b, `%s="%s"`, n, labelEscaper.Replace(v))
+	}
+	if extraName != "" {
+		if len(names) > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, extraName, extraValue)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func splitLabelKey(key string) []string {
+	return strings.Split(key, "\xff")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
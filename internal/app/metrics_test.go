@@ -0,0 +1,38 @@
+package app
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMetricsExposition(t *testing.T) {
+	r := &metricsRegistry{}
+	c := r.counter("t_jobs_total", "Jobs.", "status", "mode")
+	h := r.histogram("t_dial_seconds", "Dial.", []float64{0.1, 1}, "host")
+
+	r.add(c, 1, "success", "local")
+	r.add(c, 2, "success", "local")
+	r.add(c, 1, "failed", `on_"source"`)
+	r.observe(h, 0.05, "a")
+	r.observe(h, 0.5, "a")
+	r.observe(h, 3, "a")
+
+	var b strings.Builder
+	r.writeTo(&b)
+	got := b.String()
+	for _, want := range []string{
+		"# TYPE t_jobs_total counter\n",
+		`t_jobs_total{status="success",mode="local"} 3` + "\n",
+		`t_jobs_total{status="failed",mode="on_\"source\""} 1` + "\n",
+		"# TYPE t_dial_seconds histogram\n",
+		`t_dial_seconds_bucket{host="a",le="0.1"} 1` + "\n",
+		`t_dial_seconds_bucket{host="a",le="1"} 2` + "\n",
+		`t_dial_seconds_bucket{host="a",le="+Inf"} 3` + "\n",
+		`t_dial_seconds_sum{host="a"} 3.55` + "\n",
+		`t_dial_seconds_count{host="a"} 3` + "\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+}
@@ -7,28 +7,38 @@ import (
 
 // Endpoint：一个界面上的端点（左/右之一）
 type Endpoint struct {
-	HostName string `json:"hostName"`
-	Path     string `json:"path"`
+	HostName string   `json:"hostName" yaml:"hostName"`
+	Path     string   `json:"path" yaml:"path"`
+	Files    []string `json:"files,omitempty" yaml:"files,omitempty"` // 只传 Path 下选中的这些项（相对路径），见 selection.go
 }
 
 // RsyncOptions：部分常用选项
 type RsyncOptions struct {
-	Profile   string   `json:"profile"` // "WAN" / "LAN" / "Custom"
-	Archive   bool     `json:"archive"`
-	Compress  bool     `json:"compress"`
-	Delete    bool     `json:"delete"`
-	DryRun    bool     `json:"dryRun"`
-	BwLimit   int      `json:"bwlimit"`   // 0 = unlimited
-	ExtraArgs []string `json:"extraArgs"` // 额外参数
+	Profile   string   `json:"profile" yaml:"profile"` // "WAN" / "LAN" / "Custom"
+	Archive   bool     `json:"archive" yaml:"archive"`
+	Compress  bool     `json:"compress" yaml:"compress"`
+	Delete    bool     `json:"delete" yaml:"delete"`
+	DryRun    bool     `json:"dryRun" yaml:"dryRun"`
+	BwLimit   int      `json:"bwlimit" yaml:"bwlimit"`     // 0 = unlimited
+	ExtraArgs []string `json:"extraArgs" yaml:"extraArgs"` // 额外参数
 }
 
 // TransferRequest：前端创建任务时传过来的结构
 type TransferRequest struct {
-	EndpointA Endpoint     `json:"endpointA"`
-	EndpointB Endpoint     `json:"endpointB"`
-	Direction string       `json:"direction"` // "A_to_B" or "B_to_A"
-	ExecSide  string       `json:"execSide"`  // "auto" / "source" / "dest"
-	Options   RsyncOptions `json:"options"`
+	EndpointA Endpoint     `json:"endpointA" yaml:"endpointA"`
+	EndpointB Endpoint     `json:"endpointB" yaml:"endpointB"`
+	Direction string       `json:"direction" yaml:"direction"` // "A_to_B" or "B_to_A"
+	ExecSide  string       `json:"execSide" yaml:"execSide"`   // "auto" / "source" / "dest" / "local"（经本机中转）/ "stream"（本机流式中转）/ 其他主机名（经该主机中转）
+	Options   RsyncOptions `json:"options" yaml:"options"`
+	Priority  int          `json:"priority" yaml:"priority"` // 排队优先级，越大越先调度；同优先级 FIFO
+	Retry     RetryPolicy  `json:"retry" yaml:"retry"`       // 网络类错误自动重试
+
+	KeepStaging bool `json:"keepStaging,omitempty" yaml:"keepStaging,omitempty"` // two_step_local：保留本机 staging 作为下次增量的缓存
+
+	PreCommand  *HookCommand `json:"preCommand,omitempty" yaml:"preCommand,omitempty"`   // 传输前执行，失败则不传
+	PostCommand *HookCommand `json:"postCommand,omitempty" yaml:"postCommand,omitempty"` // 传输后执行
+
+	FanOut *FanOutSpec `json:"fanOut,omitempty" yaml:"fanOut,omitempty"` // 非空时同步到多个目标，见 fanout.go
 }
 
 type ExecMode string
@@ -38,6 +48,9 @@ const (
 	ExecOnSource     ExecMode = "on_source"      // ssh 到源机，在源机跑 rsync
 	ExecOnDest       ExecMode = "on_dest"        // ssh 到目标机
 	ExecTwoStepLocal ExecMode = "two_step_local" // A→local→B
+	ExecRelayStream  ExecMode = "relay_stream"   // 本机在内存里中转两端 rsync --server 的协议流
+	ExecViaHost      ExecMode = "via_host"       // 在第三台主机上中转 A→exec→B
+	ExecFanOut       ExecMode = "fan_out"        // 扇出父任务：每个目标一个子任务，见 fanout.go
 )
 
 type TransferPlan struct {
@@ -45,8 +58,12 @@ type TransferPlan struct {
 	Source    Endpoint  `json:"source"`
 	Dest      Endpoint  `json:"dest"`
 	ExecHost  string    `json:"execHost"` // hostName
-	TwoStep   bool      `json:"twoStep"`  // 是否需要 A→local→B
+	TwoStep   bool      `json:"twoStep"`  // 是否需要经中间机器两步传输（A→local→B / A→exec→B）
 	CreatedAt time.Time `json:"createdAt"`
+
+	Reason   string         `json:"reason,omitempty"`   // execSide=auto 时为什么这样选
+	Topology *TopologyProbe `json:"topology,omitempty"` // auto 选执行端的探测结果
+	Hops     []HopDial      `json:"hops,omitempty"`     // 每一跳连 LAN 还是 WAN 地址，见 lan.go
 }
 
 // Job 状态
@@ -58,20 +75,36 @@ const (
 	JobOK      JobStatus = "success"
 	JobFailed  JobStatus = "failed"
 	JobCancel  JobStatus = "cancelled"
+
+	JobInterrupted JobStatus = "interrupted" // 进程退出时任务还没结束（从历史加载）
 )
 
 type Job struct {
 	ID        string          `json:"id"`
+	ParentID  string          `json:"parentId,omitempty"` // 重跑/克隆自哪个任务
+	Task      string          `json:"task,omitempty"`     // 由哪个保存的 task 启动
+	Schedule  string          `json:"schedule,omitempty"` // 由哪个定时触发
+	Pipeline  string          `json:"pipeline,omitempty"` // 属于哪次流水线运行
+	FanOut    string          `json:"fanOut,omitempty"`   // 属于哪个扇出父任务
 	Request   TransferRequest `json:"request"`
 	Plan      TransferPlan    `json:"plan"`
 	Status    JobStatus       `json:"status"`
 	CreatedAt time.Time       `json:"createdAt"`
 	StartedAt time.Time       `json:"startedAt"`
 	EndedAt   time.Time       `json:"endedAt"`
-	LogLines  []string        `json:"logLines"`
+	LogLines  []string        `json:"logLines"` // 只保留最近的若干行，完整日志见 ReadLog
+	LogTotal  int             `json:"logTotal"` // 总行数（LogLines 是最后 len(LogLines) 行）
+	Attempts  []JobAttempt    `json:"attempts,omitempty"`
+	Progress  Progress        `json:"progress"`
+	Stats     *TransferStats  `json:"stats,omitempty"` // 传输结束时的统计，见 stats.go
+
+	FanOutResult *FanOutResult `json:"fanOutResult,omitempty"` // 扇出父任务：每个目标的结果，见 fanout.go
 
-	mu       sync.Mutex // 保护 LogLines & Status
-	cancelFn func()     // 未来支持取消
+	mu       sync.Mutex                 // 保护 LogLines & Status & Progress & Stats & FanOutResult
+	cancelFn func()                     // 取消本任务的 ctx（StartJob 时设置）
+	meter    progressMeter              // Progress 速率估算
+	subs     map[chan struct{}]struct{} // 变化通知，见 jobevents.go
+	logSink  jobLog                     // 日志落盘，见 joblog.go
 }
 
 type PrecheckResult struct {
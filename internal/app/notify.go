@@ -0,0 +1,311 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ===== 任务结束通知：webhook（JSON POST）和 SMTP 邮件 =====
+//
+// 全局配置在 notify.yaml；保存的 task 可以在 tasks.yaml 里写自己的 notify，写了就用它代替全局配置。
+//
+//	on: ["failed", "cancelled"]
+//	logLines: 20
+//	webhooks:
+//	  - url: "https://hooks.example.com/rsync"
+//	    headers: { Authorization: "Bearer xxx" }
+//	email:
+//	  smtpHost: "smtp.example.com"
+//	  smtpPort: 587
+//	  username: "bot@example.com"
+//	  password: "******"
+//	  from: "bot@example.com"
+//	  to: ["ops@example.com"]
+
+// NotifyConfig：什么时候通知、通知到哪里
+type NotifyConfig struct {
+	On       []JobStatus     `json:"on" yaml:"on"`             // 默认 success/failed/cancelled 都通知
+	LogLines int             `json:"logLines" yaml:"logLines"` // 附带最后几行日志，0 = 20
+	Webhooks []WebhookTarget `json:"webhooks" yaml:"webhooks"`
+	Email    *EmailTarget    `json:"email,omitempty" yaml:"email,omitempty"`
+}
+
+type WebhookTarget struct {
+	URL            string            `json:"url" yaml:"url"`
+	Headers        map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	TimeoutSeconds int               `json:"timeoutSeconds" yaml:"timeoutSeconds"` // 0 = 10s
+}
+
+type EmailTarget struct {
+	SMTPHost string   `json:"smtpHost" yaml:"smtpHost"`
+	SMTPPort int      `json:"smtpPort" yaml:"smtpPort"` // 0 = 25
+	Username string   `json:"username" yaml:"username"` // 为空则不认证
+	Password string   `json:"password" yaml:"password"`
+	From     string   `json:"from" yaml:"from"`
+	To       []string `json:"to" yaml:"to"`
+}
+
+const (
+	defaultNotifyLogLines = 20
+	defaultWebhookTimeout = 10 * time.Second
+	defaultSMTPTimeout    = 30 * time.Second
+)
+
+// JobNotification：webhook 的 body，也用来生成邮件正文
+type JobNotification struct {
+	Event           string     `json:"event"` // "job.finished"
+	Job             JobSummary `json:"job"`
+	DurationSeconds float64    `json:"durationSeconds"`
+	Source          string     `json:"source"` // host:path
+	Dest            string     `json:"dest"`
+	LogTail         []string   `json:"logTail"`
+}
+
+// LoadNotifyConfig 读取 notify.yaml；文件不存在返回 nil（不通知）
+func LoadNotifyConfig(path string) (*NotifyConfig, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read notify yaml: %w", err)
+	}
+	var cfg NotifyConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("unmarshal notify yaml: %w", err)
+	}
+	return &cfg, nil
+}
+
+func (c *NotifyConfig) wants(st JobStatus) bool {
+	if len(c.On) == 0 {
+		return st == JobOK || st == JobFailed || st == JobCancel
+	}
+	for _, s := range c.On {
+		if s == st {
+			return true
+		}
+	}
+	return false
+}
+
+// Notifier：任务结束时按配置发通知
+type Notifier struct {
+	global *NotifyConfig
+	tasks  *TaskStore
+	client *http.Client
+}
+
+func NewNotifier(global *NotifyConfig, tasks *TaskStore) *Notifier {
+	return &Notifier{global: global, tasks: tasks, client: &http.Client{}}
+}
+
+// configFor：task 自己配了 notify 就用 task 的，否则用全局的
+func (n *Notifier) configFor(job *Job) *NotifyConfig {
+	if job.Task != "" && n.tasks != nil {
+		if t, ok := n.tasks.Get(job.Task); ok && t.Notify != nil {
+			return t.Notify
+		}
+	}
+	return n.global
+}
+
+// JobFinished 注册到 JobManager.OnJobFinished；在后台发送，不阻塞任务收尾
+func (n *Notifier) JobFinished(job *Job) {
+	cfg := n.configFor(job)
+	if cfg == nil {
+		return
+	}
+	st := job.CurrentStatus()
+	if !cfg.wants(st) {
+		return
+	}
+	msg := buildJobNotification(job, cfg)
+	go n.send(context.Background(), cfg, msg)
+}
+
+func buildJobNotification(job *Job, cfg *NotifyConfig) *JobNotification {
+	tail := cfg.LogLines
+	if tail <= 0 {
+		tail = defaultNotifyLogLines
+	}
+	s := job.Summary(tail)
+	msg := &JobNotification{
+		Event:   "job.finished",
+		Job:     s,
+		Source:  s.Plan.Source.HostName + ":" + s.Plan.Source.Path,
+		Dest:    s.Plan.Dest.HostName + ":" + s.Plan.Dest.Path,
+		LogTail: s.LogLines,
+	}
+	if s.FanOutResult != nil {
+		msg.Dest = fmt.Sprintf("%d destinations", len(s.FanOutResult.Targets))
+	}
+	msg.Job.LogLines = nil
+	if !s.StartedAt.IsZero() && !s.EndedAt.IsZero() {
+		msg.DurationSeconds = s.EndedAt.Sub(s.StartedAt).Seconds()
+	}
+	return msg
+}
+
+// send 逐个目标发送；失败只打日志，互不影响
+func (n *Notifier) send(ctx context.Context, cfg *NotifyConfig, msg *JobNotification) []error {
+	var errs []error
+	for _, wh := range cfg.Webhooks {
+		if err := n.sendWebhook(ctx, wh, msg); err != nil {
+			log.Printf("[notify] job %s: webhook %s: %v", msg.Job.ID, wh.URL, err)
+			errs = append(errs, err)
+		}
+	}
+	if cfg.Email != nil && len(cfg.Email.To) > 0 {
+		if err := sendEmail(cfg.Email, msg); err != nil {
+			log.Printf("[notify] job %s: email: %v", msg.Job.ID, err)
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func (n *Notifier) sendWebhook(ctx context.Context, t WebhookTarget, msg *JobNotification) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+	timeout := defaultWebhookTimeout
+	if t.TimeoutSeconds > 0 {
+		timeout = time.Duration(t.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+func sendEmail(t *EmailTarget, msg *JobNotification) error {
+	port := t.SMTPPort
+	if port == 0 {
+		port = 25
+	}
+	addr := net.JoinHostPort(t.SMTPHost, strconv.Itoa(port))
+	if err := smtpSend(addr, t, formatEmail(t, msg)); err != nil {
+		return fmt.Errorf("send mail via %s: %w", addr, err)
+	}
+	return nil
+}
+
+// smtpSend：和 smtp.SendMail 一样的流程（有 STARTTLS 就升级），但连接和整个会话都有超时，
+// SMTP 服务器卡住不会把通知 goroutine 一直挂着
+func smtpSend(addr string, t *EmailTarget, body []byte) error {
+	conn, err := net.DialTimeout("tcp", addr, defaultSMTPTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(defaultSMTPTimeout)); err != nil {
+		return err
+	}
+
+	c, err := smtp.NewClient(conn, t.SMTPHost)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: t.SMTPHost}); err != nil {
+			return err
+		}
+	}
+	if t.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", t.Username, t.Password, t.SMTPHost)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(t.From); err != nil {
+		return err
+	}
+	for _, to := range t.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	wc, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := wc.Write(body); err != nil {
+		return err
+	}
+	if err := wc.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func formatEmail(t *EmailTarget, msg *JobNotification) []byte {
+	subject := fmt.Sprintf("[rsyncgui] job %s %s: %s -> %s", msg.Job.ID[:min(8, len(msg.Job.ID))], msg.Job.Status, msg.Source, msg.Dest)
+	if msg.Job.Task != "" {
+		subject = fmt.Sprintf("[rsyncgui] task %s %s", msg.Job.Task, msg.Job.Status)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", t.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(t.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", subject)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+
+	p := msg.Job.Progress
+	fmt.Fprintf(&b, "Job:      %s\r\n", msg.Job.ID)
+	if msg.Job.Task != "" {
+		fmt.Fprintf(&b, "Task:     %s\r\n", msg.Job.Task)
+	}
+	fmt.Fprintf(&b, "Status:   %s\r\n", msg.Job.Status)
+	fmt.Fprintf(&b, "Source:   %s\r\n", msg.Source)
+	fmt.Fprintf(&b, "Dest:     %s\r\n", msg.Dest)
+	fmt.Fprintf(&b, "Duration: %s\r\n", time.Duration(msg.DurationSeconds*float64(time.Second)).Round(time.Second))
+	fmt.Fprintf(&b, "Bytes:    %d / %d\r\n", p.BytesDone, p.BytesTotal)
+	fmt.Fprintf(&b, "Files:    %d / %d\r\n", p.FilesDone, p.FilesTotal)
+	if st := msg.Job.Stats; st != nil {
+		fmt.Fprintf(&b, "Stats:    %d files transferred, %d deleted, sent %d, received %d, literal %d, matched %d, speedup %.2f (%s)\r\n",
+			st.FilesTransferred, st.FilesDeleted, st.BytesSent, st.BytesReceived, st.LiteralData, st.MatchedData, st.Speedup, st.Source)
+	}
+	if msg.Job.Attempts > 1 {
+		fmt.Fprintf(&b, "Attempts: %d\r\n", msg.Job.Attempts)
+	}
+	if len(msg.LogTail) > 0 {
+		b.WriteString("\r\n--- last log lines ---\r\n")
+		for _, l := range msg.LogTail {
+			b.WriteString(l + "\r\n")
+		}
+	}
+	return []byte(b.String())
+}
@@ -0,0 +1,173 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testFinishedJob() *Job {
+	start := time.Date(2024, 5, 1, 2, 0, 0, 0, time.UTC)
+	job := &Job{
+		ID:        "0123456789abcdef",
+		Task:      "backup-www",
+		Status:    JobFailed,
+		CreatedAt: start,
+		StartedAt: start,
+		EndedAt:   start.Add(90 * time.Second),
+		Plan: TransferPlan{
+			Source: Endpoint{HostName: "node-01", Path: "/var/www/"},
+			Dest:   Endpoint{HostName: "node-02", Path: "/backup/www/"},
+		},
+		Progress: Progress{BytesDone: 1024, BytesTotal: 2048, FilesDone: 3, FilesTotal: 5},
+	}
+	for i := range 30 {
+		job.appendLogLocked("line " + strconv.Itoa(i))
+	}
+	return job
+}
+
+func TestNotifierWebhook(t *testing.T) {
+	got := make(chan JobNotification, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "secret" {
+			t.Errorf("missing custom header")
+		}
+		var msg JobNotification
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		got <- msg
+	}))
+	defer srv.Close()
+
+	cfg := &NotifyConfig{
+		LogLines: 5,
+		Webhooks: []WebhookTarget{{URL: srv.URL, Headers: map[string]string{"X-Token": "secret"}}},
+	}
+	n := NewNotifier(cfg, nil)
+	job := testFinishedJob()
+	if errs := n.send(context.Background(), cfg, buildJobNotification(job, cfg)); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	msg := <-got
+	if msg.Job.Status != JobFailed || msg.Job.Task != "backup-www" {
+		t.Errorf("job = %+v", msg.Job)
+	}
+	if msg.DurationSeconds != 90 {
+		t.Errorf("duration = %v, want 90", msg.DurationSeconds)
+	}
+	if len(msg.LogTail) != 5 || msg.LogTail[4] != "line 29" {
+		t.Errorf("logTail = %v", msg.LogTail)
+	}
+	if msg.Source != "node-01:/var/www/" {
+		t.Errorf("source = %q", msg.Source)
+	}
+}
+
+func TestNotifyConfigWants(t *testing.T) {
+	all := &NotifyConfig{}
+	if !all.wants(JobOK) || !all.wants(JobCancel) || all.wants(JobInterrupted) {
+		t.Error("default On should cover success/failed/cancelled only")
+	}
+	failedOnly := &NotifyConfig{On: []JobStatus{JobFailed}}
+	if failedOnly.wants(JobOK) || !failedOnly.wants(JobFailed) {
+		t.Error("On filter not applied")
+	}
+}
+
+// fakeSMTP：只实现 net/smtp.SendMail 用到的几条命令，收到的 DATA 从 channel 吐出来
+func fakeSMTP(t *testing.T) (addr string, data <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	ch := make(chan string, 1)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { _, _ = conn.Write([]byte(s + "\r\n")) }
+
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "MAIL"), strings.HasPrefix(cmd, "RCPT"):
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				var b strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					b.WriteString(l)
+				}
+				ch <- b.String()
+				reply("250 OK")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	return ln.Addr().String(), ch
+}
+
+func TestNotifierEmail(t *testing.T) {
+	addr, data := fakeSMTP(t)
+	host, portStr, _ := net.SplitHostPort(addr)
+	port, _ := strconv.Atoi(portStr)
+
+	cfg := &NotifyConfig{
+		Email: &EmailTarget{
+			SMTPHost: host,
+			SMTPPort: port,
+			From:     "bot@example.com",
+			To:       []string{"ops@example.com"},
+		},
+	}
+	n := NewNotifier(cfg, nil)
+	if errs := n.send(context.Background(), cfg, buildJobNotification(testFinishedJob(), cfg)); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	mail := <-data
+	for _, want := range []string{
+		"Subject: [rsyncgui] task backup-www failed",
+		"To: ops@example.com",
+		"Duration: 1m30s",
+		"Bytes:    1024 / 2048",
+		"line 29",
+	} {
+		if !strings.Contains(mail, want) {
+			t.Errorf("mail missing %q:\n%s", want, mail)
+		}
+	}
+}
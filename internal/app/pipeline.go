@@ -0,0 +1,570 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ===== 流水线：按顺序执行的多个步骤（传输 / 远程命令），步骤间有成功/失败条件 =====
+//
+// 有数据目录时运行记录存在 data/pipelines.json，重启后还在（没跑完的标成 interrupted）；
+// 历史清理时按 -keep-for / -keep-failed-for 一起删。
+//
+// 例：从 A 拉日志到本机 → 推到归档机 B → 不管成功与否都清理
+//
+//	{"name": "archive-logs", "steps": [
+//	  {"name": "pull",    "task": "pull-logs-a"},
+//	  {"name": "push",    "transfer": {...}},
+//	  {"name": "cleanup", "command": {"host": "local", "command": "rm -rf /tmp/logs"}, "when": "always"}
+//	]}
+
+// StepWhen：步骤在什么条件下执行（看 Needs 里步骤的结果）
+type StepWhen string
+
+const (
+	WhenOnSuccess StepWhen = "on_success" // 默认：依赖全部成功
+	WhenOnFailure StepWhen = "on_failure" // 依赖里有失败的
+	WhenAlways    StepWhen = "always"
+)
+
+// StepStatus：步骤状态，比 JobStatus 多一个 skipped
+type StepStatus string
+
+const (
+	StepPending   StepStatus = "pending"
+	StepRunning   StepStatus = "running"
+	StepOK        StepStatus = "success"
+	StepFailed    StepStatus = "failed"
+	StepSkipped   StepStatus = "skipped"
+	StepCancelled StepStatus = "cancelled"
+
+	StepInterrupted StepStatus = "interrupted" // 进程退出时步骤还没结束（从记录加载）
+)
+
+// StepCommand：在某台主机上执行的 shell 命令
+type StepCommand struct {
+	Host           string `json:"host"` // "local" 或 hosts.yaml 里的名字
+	Command        string `json:"command"`
+	TimeoutSeconds int    `json:"timeoutSeconds"` // 0 = 不限制
+}
+
+// PipelineStep：Transfer / Task / Command 三选一
+type PipelineStep struct {
+	Name     string           `json:"name"`
+	Transfer *TransferRequest `json:"transfer,omitempty"`
+	Task     string           `json:"task,omitempty"` // 保存的 task 名
+	Command  *StepCommand     `json:"command,omitempty"`
+	Needs    []string         `json:"needs,omitempty"` // 依赖的步骤（只能是前面的），默认是前一步
+	When     StepWhen         `json:"when,omitempty"`
+}
+
+// PipelineDef：提交流水线时的请求体
+type PipelineDef struct {
+	Name  string         `json:"name"`
+	Steps []PipelineStep `json:"steps"`
+}
+
+// PipelineStepRun：步骤定义 + 执行结果
+type PipelineStepRun struct {
+	PipelineStep
+	Status    StepStatus `json:"status"`
+	JobID     string     `json:"jobId,omitempty"` // 传输步骤对应的 Job
+	StartedAt time.Time  `json:"startedAt"`
+	EndedAt   time.Time  `json:"endedAt"`
+	Error     string     `json:"error,omitempty"`
+	Output    []string   `json:"output,omitempty"` // 命令步骤输出的最后若干行
+}
+
+// Pipeline：一次流水线运行；Status 汇总各步骤（任一步失败即 failed）
+type Pipeline struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	Status    JobStatus         `json:"status"`
+	CreatedAt time.Time         `json:"createdAt"`
+	StartedAt time.Time         `json:"startedAt"`
+	EndedAt   time.Time         `json:"endedAt"`
+	Steps     []PipelineStepRun `json:"steps"`
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+const maxStepOutputLines = 200
+
+var (
+	ErrPipelineNotFound  = errors.New("pipeline not found")
+	ErrPipelineNotActive = errors.New("pipeline is not running")
+	ErrPipelineInvalid   = errors.New("invalid pipeline")
+)
+
+// PipelineManager：流水线运行记录（子 Job 照常进任务历史）；path 为空时只存内存
+type PipelineManager struct {
+	app  *App
+	path string
+
+	mu    sync.RWMutex
+	runs  map[string]*Pipeline
+	order []*Pipeline
+
+	saveMu sync.Mutex
+}
+
+func NewPipelineManager(a *App) *PipelineManager {
+	return &PipelineManager{app: a, runs: make(map[string]*Pipeline)}
+}
+
+// LoadPipelines 读取保存的流水线记录；文件不存在视为空。上次没跑完的标成 interrupted
+func LoadPipelines(a *App, path string) (*PipelineManager, error) {
+	pm := NewPipelineManager(a)
+	pm.path = path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return pm, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read pipelines: %w", err)
+	}
+	var list []*Pipeline
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("unmarshal pipelines: %w", err)
+	}
+	interrupted := false
+	for _, p := range list {
+		if !p.Status.IsFinished() {
+			p.Status = JobInterrupted
+			if p.EndedAt.IsZero() {
+				p.EndedAt = time.Now()
+			}
+			for i := range p.Steps {
+				if s := &p.Steps[i]; s.Status == StepPending || s.Status == StepRunning {
+					s.Status = StepInterrupted
+				}
+			}
+			interrupted = true
+		}
+		p.cancel = func() {}
+		pm.runs[p.ID] = p
+		pm.order = append(pm.order, p)
+	}
+	sort.SliceStable(pm.order, func(i, j int) bool { return pm.order[i].CreatedAt.Before(pm.order[j].CreatedAt) })
+	if interrupted {
+		pm.save()
+	}
+	return pm, nil
+}
+
+// save 把全部记录整体写回文件；失败只记日志，不影响执行
+func (pm *PipelineManager) save() {
+	if pm.path == "" {
+		return
+	}
+	pm.saveMu.Lock()
+	defer pm.saveMu.Unlock()
+
+	pm.mu.RLock()
+	list := make([]*Pipeline, 0, len(pm.order))
+	for _, p := range pm.order {
+		list = append(list, p.Snapshot())
+	}
+	pm.mu.RUnlock()
+
+	if err := writeJSONFile(pm.path, list); err != nil {
+		log.Printf("[pipeline] save %s: %v", pm.path, err)
+	}
+}
+
+// prune：按保留策略删除已结束的流水线记录（子 Job 由 PruneJobs 自己按同样的规则删），返回删掉的 ID
+func (pm *PipelineManager) prune(now time.Time, policy RetentionPolicy, dryRun bool) []string {
+	pm.mu.Lock()
+	var removed []string
+	kept := pm.order[:0]
+	for _, p := range pm.order {
+		p.mu.Lock()
+		status, ended := p.Status, p.EndedAt
+		p.mu.Unlock()
+		if policy.recordExpired(status, ended, now) {
+			removed = append(removed, p.ID)
+			if !dryRun {
+				delete(pm.runs, p.ID)
+				continue
+			}
+		}
+		kept = append(kept, p)
+	}
+	clear(pm.order[len(kept):])
+	pm.order = kept
+	pm.mu.Unlock()
+
+	if len(removed) > 0 && !dryRun {
+		pm.save()
+	}
+	return removed
+}
+
+// validate 检查步骤定义；Needs 只能引用前面的步骤，所以不会有环
+func (d *PipelineDef) validate() error {
+	if len(d.Steps) == 0 {
+		return fmt.Errorf("%w: no steps", ErrPipelineInvalid)
+	}
+	seen := make(map[string]bool, len(d.Steps))
+	for i := range d.Steps {
+		st := &d.Steps[i]
+		if st.Name == "" {
+			st.Name = fmt.Sprintf("step-%d", i+1)
+		}
+		if seen[st.Name] {
+			return fmt.Errorf("%w: duplicate step %q", ErrPipelineInvalid, st.Name)
+		}
+
+		kinds := 0
+		if st.Transfer != nil {
+			kinds++
+		}
+		if st.Task != "" {
+			kinds++
+		}
+		if st.Command != nil {
+			kinds++
+			if st.Command.Host == "" || strings.TrimSpace(st.Command.Command) == "" {
+				return fmt.Errorf("%w: step %q: command needs host and command", ErrPipelineInvalid, st.Name)
+			}
+		}
+		if kinds != 1 {
+			return fmt.Errorf("%w: step %q: exactly one of transfer, task or command is required", ErrPipelineInvalid, st.Name)
+		}
+
+		switch st.When {
+		case "":
+			st.When = WhenOnSuccess
+		case WhenOnSuccess, WhenOnFailure, WhenAlways:
+		default:
+			return fmt.Errorf("%w: step %q: unknown when %q", ErrPipelineInvalid, st.Name, st.When)
+		}
+
+		if st.Needs == nil && i > 0 {
+			st.Needs = []string{d.Steps[i-1].Name}
+		}
+		for _, n := range st.Needs {
+			if !seen[n] {
+				return fmt.Errorf("%w: step %q needs %q, which is not an earlier step", ErrPipelineInvalid, st.Name, n)
+			}
+		}
+		seen[st.Name] = true
+	}
+	return nil
+}
+
+// Start 校验定义并在后台开始执行
+func (pm *PipelineManager) Start(def PipelineDef) (*Pipeline, error) {
+	if err := def.validate(); err != nil {
+		return nil, err
+	}
+	for _, st := range def.Steps {
+		if st.Task != "" {
+			if _, ok := pm.app.Tasks.Get(st.Task); !ok {
+				return nil, fmt.Errorf("%w: step %q: task %q not found", ErrPipelineInvalid, st.Name, st.Task)
+			}
+		}
+		if st.Command != nil {
+			if _, ok := pm.app.Hosts.Get(st.Command.Host); !ok {
+				return nil, fmt.Errorf("%w: step %q: unknown host %q", ErrPipelineInvalid, st.Name, st.Command.Host)
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Pipeline{
+		ID:        uuid.New().String(),
+		Name:      def.Name,
+		Status:    JobPending,
+		CreatedAt: time.Now(),
+		cancel:    cancel,
+	}
+	for _, st := range def.Steps {
+		p.Steps = append(p.Steps, PipelineStepRun{PipelineStep: st, Status: StepPending})
+	}
+
+	pm.mu.Lock()
+	pm.runs[p.ID] = p
+	pm.order = append(pm.order, p)
+	pm.mu.Unlock()
+	pm.save()
+
+	go pm.run(ctx, p)
+	return p, nil
+}
+
+func (pm *PipelineManager) Get(id string) (*Pipeline, bool) {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	p, ok := pm.runs[id]
+	return p, ok
+}
+
+// List：最新的排前面
+func (pm *PipelineManager) List() []*Pipeline {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	out := make([]*Pipeline, 0, len(pm.order))
+	for i := len(pm.order) - 1; i >= 0; i-- {
+		out = append(out, pm.order[i])
+	}
+	return out
+}
+
+// Cancel 取消整条流水线：正在跑的步骤被取消，后面的步骤（包括 always）不再执行
+func (pm *PipelineManager) Cancel(id string) (*Pipeline, error) {
+	p, ok := pm.Get(id)
+	if !ok {
+		return nil, ErrPipelineNotFound
+	}
+	p.mu.Lock()
+	finished := p.Status.IsFinished()
+	p.mu.Unlock()
+	if finished {
+		return nil, ErrPipelineNotActive
+	}
+	p.cancel()
+	return p, nil
+}
+
+// Snapshot 在锁内拷贝一份可以安全 JSON 编码的 Pipeline
+func (p *Pipeline) Snapshot() *Pipeline {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	cp := &Pipeline{
+		ID:        p.ID,
+		Name:      p.Name,
+		Status:    p.Status,
+		CreatedAt: p.CreatedAt,
+		StartedAt: p.StartedAt,
+		EndedAt:   p.EndedAt,
+		Steps:     make([]PipelineStepRun, len(p.Steps)),
+	}
+	copy(cp.Steps, p.Steps)
+	for i := range cp.Steps {
+		cp.Steps[i].Output = append([]string(nil), p.Steps[i].Output...)
+	}
+	return cp
+}
+
+func (pm *PipelineManager) run(ctx context.Context, p *Pipeline) {
+	defer p.cancel()
+
+	p.mu.Lock()
+	p.Status = JobRunning
+	p.StartedAt = time.Now()
+	p.mu.Unlock()
+
+	failed := false
+	for i := range p.Steps {
+		if ctx.Err() != nil {
+			p.setStep(i, func(s *PipelineStepRun) { s.Status = StepCancelled })
+			continue
+		}
+		if !p.shouldRun(i) {
+			p.setStep(i, func(s *PipelineStepRun) { s.Status = StepSkipped })
+			continue
+		}
+
+		p.setStep(i, func(s *PipelineStepRun) {
+			s.Status = StepRunning
+			s.StartedAt = time.Now()
+		})
+		status, err := pm.runStep(ctx, p, i)
+		p.setStep(i, func(s *PipelineStepRun) {
+			s.Status = status
+			s.EndedAt = time.Now()
+			if err != nil {
+				s.Error = err.Error()
+			}
+		})
+		if status == StepFailed {
+			failed = true
+		}
+		pm.save()
+	}
+
+	p.mu.Lock()
+	switch {
+	case ctx.Err() != nil:
+		p.Status = JobCancel
+	case failed:
+		p.Status = JobFailed
+	default:
+		p.Status = JobOK
+	}
+	p.EndedAt = time.Now()
+	status := p.Status
+	p.mu.Unlock()
+	pm.save()
+	log.Printf("[pipeline] %s (%s) finished: %s", p.ID, p.Name, status)
+}
+
+func (p *Pipeline) setStep(i int, fn func(*PipelineStepRun)) {
+	p.mu.Lock()
+	fn(&p.Steps[i])
+	p.mu.Unlock()
+}
+
+// effectiveFailedLocked：跳过的步骤沿用它依赖的结果，这样 on_failure 能看到更前面的失败
+func (p *Pipeline) effectiveFailedLocked(i int) bool {
+	s := &p.Steps[i]
+	switch s.Status {
+	case StepFailed, StepCancelled:
+		return true
+	case StepSkipped:
+		for _, n := range s.Needs {
+			if j := p.stepIndexLocked(n); j >= 0 && p.effectiveFailedLocked(j) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *Pipeline) stepIndexLocked(name string) int {
+	for i := range p.Steps {
+		if p.Steps[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (p *Pipeline) shouldRun(i int) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s := &p.Steps[i]
+	anyFailed := false
+	for _, n := range s.Needs {
+		if j := p.stepIndexLocked(n); j >= 0 && p.effectiveFailedLocked(j) {
+			anyFailed = true
+		}
+	}
+	switch s.When {
+	case WhenAlways:
+		return true
+	case WhenOnFailure:
+		return anyFailed
+	default:
+		return !anyFailed
+	}
+}
+
+func (pm *PipelineManager) runStep(ctx context.Context, p *Pipeline, i int) (StepStatus, error) {
+	p.mu.Lock()
+	step := p.Steps[i].PipelineStep
+	p.mu.Unlock()
+
+	if step.Command != nil {
+		return pm.runCommandStep(ctx, p, i, step.Command)
+	}
+
+	opts := SubmitOptions{Pipeline: p.ID}
+	var (
+		sub *Submission
+		err error
+	)
+	if step.Task != "" {
+		sub, err = pm.app.RunTask(step.Task, opts)
+	} else {
+		sub, err = pm.app.SubmitTransfer(*step.Transfer, opts)
+	}
+	if err != nil {
+		if errors.Is(err, ErrPrecheckFailed) && sub != nil && sub.Precheck != nil {
+			err = fmt.Errorf("%w: %s", err, sub.Precheck.Message)
+		}
+		return StepFailed, err
+	}
+	p.setStep(i, func(s *PipelineStepRun) { s.JobID = sub.Job.ID })
+	pm.save()
+
+	switch pm.app.JobManager.waitJob(ctx, sub.Job) {
+	case JobOK:
+		return StepOK, nil
+	case JobCancel:
+		return StepCancelled, errors.New("job cancelled")
+	default:
+		return StepFailed, fmt.Errorf("job %s failed", sub.Job.ID)
+	}
+}
+
+func (pm *PipelineManager) runCommandStep(ctx context.Context, p *Pipeline, i int, c *StepCommand) (StepStatus, error) {
+	h, ok := pm.app.Hosts.Get(c.Host)
+	if !ok {
+		return StepFailed, fmt.Errorf("unknown host %q", c.Host)
+	}
+	if c.TimeoutSeconds > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(c.TimeoutSeconds)*time.Second)
+		defer cancel()
+	}
+
+	out := &stepOutputWriter{p: p, i: i}
+	err := h.RunContext(ctx, c.Command, out)
+	out.Flush()
+	switch {
+	case err == nil:
+		return StepOK, nil
+	case errors.Is(err, context.Canceled):
+		return StepCancelled, err
+	default:
+		return StepFailed, err
+	}
+}
+
+// stepOutputWriter：命令输出按行存到步骤里，只保留最后 maxStepOutputLines 行
+type stepOutputWriter struct {
+	p   *Pipeline
+	i   int
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (w *stepOutputWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n, _ := w.buf.Write(b)
+	for {
+		data := w.buf.Bytes()
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		line := strings.TrimRight(string(data[:i]), "\r")
+		w.buf.Next(i + 1)
+		w.appendLine(line)
+	}
+	return n, nil
+}
+
+func (w *stepOutputWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.buf.Len() > 0 {
+		w.appendLine(strings.TrimRight(w.buf.String(), "\r"))
+		w.buf.Reset()
+	}
+}
+
+func (w *stepOutputWriter) appendLine(line string) {
+	w.p.setStep(w.i, func(s *PipelineStepRun) {
+		s.Output = append(s.Output, line)
+		if len(s.Output) > maxStepOutputLines {
+			s.Output = s.Output[len(s.Output)-maxStepOutputLines:]
+		}
+	})
+}
@@ -0,0 +1,152 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func waitPipeline(t *testing.T, p *Pipeline) *Pipeline {
+	t.Helper()
+	deadline := time.Now().Add(30 * time.Second)
+	snap := p.Snapshot()
+	for !snap.Status.IsFinished() {
+		if time.Now().After(deadline) {
+			t.Fatalf("pipeline did not finish: %+v", snap)
+		}
+		time.Sleep(20 * time.Millisecond)
+		snap = p.Snapshot()
+	}
+	return snap
+}
+
+func TestPipelineRun(t *testing.T) {
+	reg, err := NewHostRegistry(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &App{Hosts: reg}
+	store := filepath.Join(t.TempDir(), "pipelines.json")
+	if a.Pipelines, err = LoadPipelines(a, store); err != nil {
+		t.Fatal(err)
+	}
+	cmd := func(c string) *StepCommand { return &StepCommand{Host: "local", Command: c} }
+
+	if _, err := a.Pipelines.Start(PipelineDef{Steps: []PipelineStep{
+		{Name: "a", Command: cmd("true")},
+		{Name: "b", Command: cmd("true"), Needs: []string{"c"}},
+		{Name: "c", Command: cmd("true")},
+	}}); err == nil {
+		t.Fatal("expected error for needs on a later step")
+	}
+
+	// build 失败：deploy 跳过，notify 看得到更前面的失败，cleanup 总是执行
+	p, err := a.Pipelines.Start(PipelineDef{Name: "edges", Steps: []PipelineStep{
+		{Name: "build", Command: cmd("echo building; exit 3")},
+		{Name: "deploy", Command: cmd("echo deploying")},
+		{Name: "notify", Command: cmd("echo recovered"), Needs: []string{"deploy"}, When: WhenOnFailure},
+		{Name: "cleanup", Command: cmd("echo cleanup"), When: WhenAlways},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap := waitPipeline(t, p)
+	want := map[string]StepStatus{"build": StepFailed, "deploy": StepSkipped, "notify": StepOK, "cleanup": StepOK}
+	for _, s := range snap.Steps {
+		if s.Status != want[s.Name] {
+			t.Errorf("step %s = %s, want %s (%s)", s.Name, s.Status, want[s.Name], s.Error)
+		}
+	}
+	if snap.Status != JobFailed {
+		t.Fatalf("status = %s", snap.Status)
+	}
+	// bash -l 可能先打出 profile 里的东西，只看最后一行
+	if out := snap.Steps[2].Output; len(out) == 0 || out[len(out)-1] != "recovered" {
+		t.Fatalf("notify output = %q", out)
+	}
+
+	// 全部成功
+	ok, err := a.Pipelines.Start(PipelineDef{Name: "ok", Steps: []PipelineStep{
+		{Name: "one", Command: cmd("true")},
+		{Name: "two", Command: cmd("true")},
+		{Name: "on-fail", Command: cmd("true"), When: WhenOnFailure},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap := waitPipeline(t, ok); snap.Status != JobOK || snap.Steps[2].Status != StepSkipped {
+		t.Fatalf("ok pipeline = %s, on-fail = %s", snap.Status, snap.Steps[2].Status)
+	}
+
+	// 取消：正在跑的步骤被取消，后面的 always 也不再执行
+	slow, err := a.Pipelines.Start(PipelineDef{Name: "slow", Steps: []PipelineStep{
+		{Name: "sleep", Command: cmd("exec sleep 30")},
+		{Name: "cleanup", Command: cmd("true"), When: WhenAlways},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for slow.Snapshot().Steps[0].Status != StepRunning {
+		if time.Now().After(deadline) {
+			t.Fatal("sleep step did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := a.Pipelines.Cancel(slow.ID); err != nil {
+		t.Fatal(err)
+	}
+	snap = waitPipeline(t, slow)
+	if snap.Status != JobCancel || snap.Steps[0].Status != StepCancelled || snap.Steps[1].Status != StepCancelled {
+		t.Fatalf("cancelled pipeline = %s, steps %s/%s", snap.Status, snap.Steps[0].Status, snap.Steps[1].Status)
+	}
+	if _, err := a.Pipelines.Cancel(slow.ID); err != ErrPipelineNotActive {
+		t.Fatalf("second cancel: %v", err)
+	}
+
+	// 记录落盘，重启后还在；过了保留期会被清理
+	reloaded, err := LoadPipelines(a, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok2 := reloaded.Get(p.ID)
+	if !ok2 || got.Status != JobFailed || got.Steps[3].Status != StepOK || len(reloaded.List()) != 3 {
+		t.Fatalf("reloaded = %+v (%d records)", got, len(reloaded.List()))
+	}
+	policy := RetentionPolicy{MaxAge: time.Hour}
+	if removed := reloaded.prune(time.Now(), policy, false); len(removed) != 0 {
+		t.Fatalf("pruned too early: %v", removed)
+	}
+	if removed := reloaded.prune(time.Now().Add(2*time.Hour), policy, false); len(removed) != 3 || len(reloaded.List()) != 0 {
+		t.Fatalf("pruned %v, %d left", removed, len(reloaded.List()))
+	}
+	if again, err := LoadPipelines(a, store); err != nil || len(again.List()) != 0 {
+		t.Fatalf("after prune: %v, %d left", err, len(again.List()))
+	}
+}
+
+func TestLoadPipelinesMarksInterrupted(t *testing.T) {
+	store := filepath.Join(t.TempDir(), "pipelines.json")
+	data := `[{"id":"p1","name":"x","status":"running","steps":[` +
+		`{"name":"a","status":"success"},{"name":"b","status":"running"},{"name":"c","status":"pending"}]}]`
+	if err := os.WriteFile(store, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pm, err := LoadPipelines(&App{}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, ok := pm.Get("p1")
+	if !ok || p.Status != JobInterrupted || p.EndedAt.IsZero() {
+		t.Fatalf("p1 = %+v", p)
+	}
+	for i, want := range []StepStatus{StepOK, StepInterrupted, StepInterrupted} {
+		if p.Steps[i].Status != want {
+			t.Fatalf("step %d = %s, want %s", i, p.Steps[i].Status, want)
+		}
+	}
+	if _, err := pm.Cancel("p1"); err != ErrPipelineNotActive {
+		t.Fatalf("cancel interrupted: %v", err)
+	}
+}
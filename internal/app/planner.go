@@ -1,29 +1,134 @@
 package app
 
 import (
+	"context"
 	"fmt"
+	"path/filepath"
 	"time"
 )
 
 type App struct {
 	Hosts      *HostRegistry
 	JobManager *JobManager
+	Tasks      *TaskStore
+	Schedules  *Scheduler
+	Pipelines  *PipelineManager
+	Notifier   *Notifier
+
+	topology topologyCache // execSide=auto 的拓扑探测结果
+	lan      lanCache      // LAN 地址是否连得通
+}
+
+// AppOptions：NewApp 的可选配置
+type AppOptions struct {
+	DataDir       string // 任务历史等数据的目录；为空则只存内存
+	MaxConcurrent int    // 全局同时运行的任务数上限，0 = 不限制
+	TasksPath     string // 保存的传输任务（tasks.yaml）
+	SchedulesPath string // 定时（schedules.yaml）
+	NotifyPath    string // 任务结束通知的全局配置（notify.yaml），不存在则不通知
+	Retention     RetentionPolicy
 }
 
 // NewApp 初始化核心 app
-func NewApp(hostConfigs []HostConfig) (*App, error) {
+func NewApp(hostConfigs []HostConfig, opts AppOptions) (*App, error) {
 	reg, err := NewHostRegistry(hostConfigs)
 	if err != nil {
 		return nil, err
 	}
-	return &App{
+
+	var store JobStore
+	if opts.DataDir != "" {
+		store, err = OpenJSONLJobStore(opts.DataDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+	jmOpts := JobManagerOptions{
+		Store:         store,
+		MaxConcurrent: opts.MaxConcurrent,
+		Retention:     opts.Retention,
+	}
+	if opts.DataDir != "" {
+		jmOpts.LogDir = filepath.Join(opts.DataDir, "logs")
+		jmOpts.StagingDir = filepath.Join(opts.DataDir, "staging")
+	}
+	jm, err := NewJobManager(reg, jmOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	tasksPath := opts.TasksPath
+	if tasksPath == "" {
+		tasksPath = "tasks.yaml"
+	}
+	tasks, err := LoadTasks(tasksPath)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &App{
 		Hosts:      reg,
-		JobManager: NewJobManager(reg),
-	}, nil
+		JobManager: jm,
+		Tasks:      tasks,
+	}
+	if opts.DataDir != "" {
+		a.Pipelines, err = LoadPipelines(a, filepath.Join(opts.DataDir, "pipelines.json"))
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		a.Pipelines = NewPipelineManager(a)
+	}
+	jm.prunePipelines = a.Pipelines.prune
+	jm.OnJobFinished(observeJobMetrics)
+
+	if opts.NotifyPath != "" {
+		notifyCfg, err := LoadNotifyConfig(opts.NotifyPath)
+		if err != nil {
+			return nil, err
+		}
+		a.Notifier = NewNotifier(notifyCfg, tasks)
+		jm.OnJobFinished(a.Notifier.JobFinished)
+	}
+
+	schedulesPath := opts.SchedulesPath
+	if schedulesPath == "" {
+		schedulesPath = "schedules.yaml"
+	}
+	a.Schedules, err = LoadSchedules(a, schedulesPath)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
 }
 
-// PlanTransfer：把 Request 变成 Plan（不做 IO，只是决策）
+// planProbeTimeout：生成计划时 SSH 探测（execSide=auto 的拓扑、每一跳的 LAN 地址）最多等这么久，
+// 超时的探测按失败处理（走 WAN / 默认执行端）
+const planProbeTimeout = 15 * time.Second
+
+// PlanTransfer：把 Request 变成 Plan（只做决策；两端都是远程且 execSide=auto 时会 ssh 探测拓扑）
 func (a *App) PlanTransfer(req TransferRequest) (*TransferPlan, error) {
+	return a.PlanTransferContext(context.Background(), req)
+}
+
+// PlanTransferContext：PlanTransfer，探测随 ctx 取消（比如 HTTP 请求断开）
+func (a *App) PlanTransferContext(ctx context.Context, req TransferRequest) (*TransferPlan, error) {
+	ctx, cancel := context.WithTimeout(ctx, planProbeTimeout)
+	defer cancel()
+	return a.planTransfer(ctx, req, true)
+}
+
+// ValidateTransfer：只检查请求能不能生成计划，不做任何探测（保存 task 时用，执行时再探测）
+func (a *App) ValidateTransfer(req TransferRequest) error {
+	_, err := a.planTransfer(context.Background(), req, false)
+	return err
+}
+
+func (a *App) planTransfer(ctx context.Context, req TransferRequest, probe bool) (*TransferPlan, error) {
+	if req.FanOut != nil {
+		return a.planFanOut(req)
+	}
+
 	// 1. 根据 direction 决定 source/dest
 	var source, dest Endpoint
 	switch req.Direction {
@@ -36,6 +141,12 @@ func (a *App) PlanTransfer(req TransferRequest) (*TransferPlan, error) {
 	default:
 		return nil, fmt.Errorf("invalid direction: %s", req.Direction)
 	}
+	if err := validateSelection(source, dest); err != nil {
+		return nil, err
+	}
+	if err := validateHooks(&req); err != nil {
+		return nil, err
+	}
 
 	srcHost, ok := a.Hosts.Get(source.HostName)
 	if !ok {
@@ -53,15 +164,14 @@ func (a *App) PlanTransfer(req TransferRequest) (*TransferPlan, error) {
 	}
 
 	// 2. 决定执行模式
-	if srcHost.IsLocal && dstHost.IsLocal {
-		return nil, fmt.Errorf("local to local transfer is not allowed")
-	}
-
 	if srcHost.IsLocal || dstHost.IsLocal {
-		// 一端是本机 → 在本机跑 rsync
+		// 有一端是本机（包括本机 → 本机）→ 在本机用 Go-native rsync
 		plan.Mode = ExecLocal
 		plan.ExecHost = "local"
 		plan.TwoStep = false
+		if probe {
+			a.planHops(ctx, plan, srcHost, dstHost, preferLan(req))
+		}
 		return plan, nil
 	}
 
@@ -75,12 +185,56 @@ func (a *App) PlanTransfer(req TransferRequest) (*TransferPlan, error) {
 		plan.Mode = ExecOnDest
 		plan.ExecHost = dstHost.Config.Name
 		plan.TwoStep = false
-	default: // "auto"
-		// 简单版本：优先源机执行，以后可以加拓扑判断
-		plan.Mode = ExecOnSource
-		plan.ExecHost = srcHost.Config.Name
+	case "local":
+		// 两台远程互相连不通：经本机中转
+		plan.Mode = ExecTwoStepLocal
+		plan.ExecHost = "local"
+		plan.TwoStep = true
+	case "stream":
+		// 两台远程互相连不通，又不想经本机磁盘：本机转发协议流
+		plan.Mode = ExecRelayStream
+		plan.ExecHost = "local"
 		plan.TwoStep = false
+	case "auto", "":
+		if !probe {
+			plan.Mode, plan.ExecHost = ExecOnSource, srcHost.Config.Name
+			plan.Reason = "auto: exec side is decided when the transfer runs"
+			break
+		}
+		plan.Topology = a.probeTopology(ctx, srcHost, dstHost, preferLan(req))
+		plan.Mode, plan.Reason = chooseExecSide(plan.Topology)
+		switch plan.Mode {
+		case ExecOnDest:
+			plan.ExecHost = dstHost.Config.Name
+		case ExecTwoStepLocal:
+			plan.ExecHost = "local"
+			plan.TwoStep = true
+		default:
+			plan.ExecHost = srcHost.Config.Name
+		}
+	default:
+		// 其他主机名：源和目标互相连不通，但这台主机两边都能连
+		execHost, ok := a.Hosts.Get(req.ExecSide)
+		if !ok {
+			return nil, fmt.Errorf("unknown exec host: %s", req.ExecSide)
+		}
+		switch execHost.Config.Name {
+		case srcHost.Config.Name:
+			plan.Mode = ExecOnSource
+		case dstHost.Config.Name:
+			plan.Mode = ExecOnDest
+		default:
+			plan.Mode = ExecViaHost
+			plan.TwoStep = true
+		}
+		plan.ExecHost = execHost.Config.Name
+	}
+	if req.Options.DryRun && (plan.Mode == ExecTwoStepLocal || plan.Mode == ExecViaHost) {
+		return nil, ErrRelayDryRun
 	}
 
+	if probe {
+		a.planHops(ctx, plan, srcHost, dstHost, preferLan(req))
+	}
 	return plan, nil
 }
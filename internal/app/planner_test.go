@@ -0,0 +1,68 @@
+package app
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPlanTransferExecSideHost(t *testing.T) {
+	reg, err := NewHostRegistry([]HostConfig{{Name: "a"}, {Name: "b"}, {Name: "bastion"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &App{Hosts: reg}
+
+	// 和 execSide 关键字重名的主机永远选不成中转机，直接拒绝
+	for _, name := range []string{"auto", "source", "dest", "local", "stream"} {
+		if _, err := NewHostRegistry([]HostConfig{{Name: name}}); err == nil {
+			t.Fatalf("host %q: expected error", name)
+		}
+	}
+
+	req := TransferRequest{
+		EndpointA: Endpoint{HostName: "a", Path: "/data/"},
+		EndpointB: Endpoint{HostName: "b", Path: "/backup/"},
+		Direction: "A_to_B",
+	}
+
+	cases := []struct {
+		execSide string
+		mode     ExecMode
+		execHost string
+	}{
+		{"bastion", ExecViaHost, "bastion"},
+		{"a", ExecOnSource, "a"},
+		{"b", ExecOnDest, "b"},
+		{"local", ExecTwoStepLocal, "local"},
+	}
+	for _, c := range cases {
+		req.ExecSide = c.execSide
+		plan, err := a.PlanTransfer(req)
+		if err != nil {
+			t.Fatalf("%s: %v", c.execSide, err)
+		}
+		if plan.Mode != c.mode || plan.ExecHost != c.execHost {
+			t.Errorf("%s: got %s on %s, want %s on %s", c.execSide, plan.Mode, plan.ExecHost, c.mode, c.execHost)
+		}
+	}
+
+	// 经 staging 中转的 dry run 会把整个源拉进 staging，直接拒绝；流式中转不落盘，可以 dry run
+	req.Options.DryRun = true
+	for _, c := range cases {
+		req.ExecSide = c.execSide
+		_, err := a.PlanTransfer(req)
+		if relay := c.mode == ExecTwoStepLocal || c.mode == ExecViaHost; relay != errors.Is(err, ErrRelayDryRun) {
+			t.Errorf("%s dry run: %v", c.execSide, err)
+		}
+	}
+	req.ExecSide = "stream"
+	if _, err := a.PlanTransfer(req); err != nil {
+		t.Errorf("stream dry run: %v", err)
+	}
+	req.Options.DryRun = false
+
+	req.ExecSide = "nope"
+	if _, err := a.PlanTransfer(req); err == nil {
+		t.Fatal("unknown exec host should fail")
+	}
+}
@@ -36,30 +36,23 @@ func (a *App) checkSourceReadable(plan *TransferPlan) error {
 	if !ok {
 		return fmt.Errorf("unknown source host %s", plan.Source.HostName)
 	}
-	path := plan.Source.Path
+	// 有文件选择时每个选中项都要能读
+	paths := plan.Source.sourcePaths()
 
 	if src.IsLocal {
-		// ✅ Windows/Linux 都可：不用 shell
-		st, err := os.Stat(path)
-		if err != nil {
-			return fmt.Errorf("local source not readable: %v", err)
-		}
-		if st.IsDir() {
-			_, err := os.ReadDir(path)
-			if err != nil {
-				return fmt.Errorf("local source not readable: %v", err)
+		for _, path := range paths {
+			if err := checkLocalReadable(path); err != nil {
+				return err
 			}
-			return nil
 		}
-		f, err := os.Open(path)
-		if err != nil {
-			return fmt.Errorf("local source not readable: %v", err)
-		}
-		_ = f.Close()
 		return nil
 	}
 
-	cmd := fmt.Sprintf(`test -r %q && echo OK || echo NO`, path)
+	tests := make([]string, 0, len(paths))
+	for _, path := range paths {
+		tests = append(tests, fmt.Sprintf("test -r %q", path))
+	}
+	cmd := strings.Join(tests, " && ") + " && echo OK || echo NO"
 	out, err := runSSH(src, cmd)
 	if err != nil {
 		return fmt.Errorf("remote source read check failed: %v", err)
@@ -70,6 +63,27 @@ func (a *App) checkSourceReadable(plan *TransferPlan) error {
 	return nil
 }
 
+// checkLocalReadable：✅ Windows/Linux 都可：不用 shell
+func checkLocalReadable(path string) error {
+	st, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("local source not readable: %v", err)
+	}
+	if st.IsDir() {
+		_, err := os.ReadDir(path)
+		if err != nil {
+			return fmt.Errorf("local source not readable: %v", err)
+		}
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("local source not readable: %v", err)
+	}
+	_ = f.Close()
+	return nil
+}
+
 func (a *App) checkDestWritable(plan *TransferPlan) error {
 	dst, ok := a.Hosts.Get(plan.Dest.HostName)
 	if !ok {
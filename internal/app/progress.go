@@ -0,0 +1,339 @@
+package app
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Progress：结构化的传输进度，给前端画进度条用
+type Progress struct {
+	BytesTotal  int64     `json:"bytesTotal"` // 0 = 未知
+	BytesDone   int64     `json:"bytesDone"`
+	FilesTotal  int64     `json:"filesTotal"` // 0 = 未知
+	FilesDone   int64     `json:"filesDone"`
+	CurrentFile string    `json:"currentFile,omitempty"`
+	Rate        float64   `json:"rate"`       // bytes/s
+	ETASeconds  int64     `json:"etaSeconds"` // -1 = 未知
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// progressMeter：按字节计数估算速率（EWMA）
+type progressMeter struct {
+	lastAt    time.Time
+	lastBytes int64
+}
+
+const progressRateWindow = time.Second
+
+func (j *Job) resetProgress() {
+	j.mu.Lock()
+	j.Progress = Progress{ETASeconds: -1, UpdatedAt: time.Now()}
+	j.meter = progressMeter{lastAt: time.Now()}
+	j.notifyLocked()
+	j.mu.Unlock()
+}
+
+// setProgressTotals：开始前已知的总量（比如本机源目录扫描出来的）
+func (j *Job) setProgressTotals(bytesTotal, filesTotal int64) {
+	j.mu.Lock()
+	j.Progress.BytesTotal = bytesTotal
+	j.Progress.FilesTotal = filesTotal
+	j.Progress.UpdatedAt = time.Now()
+	j.notifyLocked()
+	j.mu.Unlock()
+}
+
+// addProgressBytes：计数器驱动的路径（Go-native 管道、scp/tar）调用，速率和 ETA 在这里算
+func (j *Job) addProgressBytes(n int64) {
+	if n <= 0 {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	p := &j.Progress
+	p.BytesDone += n
+	now := time.Now()
+	p.UpdatedAt = now
+	j.notifyLocked()
+
+	dt := now.Sub(j.meter.lastAt)
+	if dt < progressRateWindow {
+		return
+	}
+	inst := float64(p.BytesDone-j.meter.lastBytes) / dt.Seconds()
+	if p.Rate == 0 {
+		p.Rate = inst
+	} else {
+		p.Rate = 0.3*inst + 0.7*p.Rate
+	}
+	j.meter.lastAt = now
+	j.meter.lastBytes = p.BytesDone
+	p.ETASeconds = estimateETA(p.BytesTotal, p.BytesDone, p.Rate)
+}
+
+func (j *Job) addProgressFile(name string) {
+	j.mu.Lock()
+	j.Progress.FilesDone++
+	j.Progress.CurrentFile = name
+	j.Progress.UpdatedAt = time.Now()
+	j.notifyLocked()
+	j.mu.Unlock()
+}
+
+// finishProgress：成功结束时把进度补满
+func (j *Job) finishProgress() {
+	j.mu.Lock()
+	p := &j.Progress
+	if p.BytesTotal > 0 && p.BytesDone < p.BytesTotal {
+		p.BytesDone = p.BytesTotal
+	}
+	if p.FilesTotal > 0 {
+		p.FilesDone = p.FilesTotal
+	}
+	p.CurrentFile = ""
+	p.ETASeconds = 0
+	p.UpdatedAt = time.Now()
+	j.notifyLocked()
+	j.mu.Unlock()
+}
+
+func estimateETA(total, done int64, rate float64) int64 {
+	if total <= 0 || rate <= 0 {
+		return -1
+	}
+	if done >= total {
+		return 0
+	}
+	return int64(float64(total-done) / rate)
+}
+
+// localPathTotals：统计本机源路径下的字节数和文件数（只算普通文件）
+func localPathTotals(path string) (bytesTotal, filesTotal int64) {
+	_ = filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		bytesTotal += info.Size()
+		filesTotal++
+		return nil
+	})
+	return bytesTotal, filesTotal
+}
+
+// ===== SSH 管道上的字节计数 =====
+
+type progressReader struct {
+	r   io.Reader
+	job *Job
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.job.addProgressBytes(int64(n))
+	return n, err
+}
+
+type progressWriter struct {
+	w   io.Writer
+	job *Job
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.job.addProgressBytes(int64(n))
+	return n, err
+}
+
+// ===== 解析命令行 rsync 的 --info=progress2 输出 =====
+
+// 1,234,567  45%   12.34MB/s    0:00:10 (xfr#5, to-chk=10/20)
+var progress2Re = regexp.MustCompile(`^\s*([\d,]+)\s+(\d+)%\s+([\d.]+)([kKMGT]?)B/s\s+(\d+):(\d{2}):(\d{2})(?:\s+\(xfr#(\d+),\s*(?:to|ir)-chk=(\d+)/(\d+)\))?`)
+
+// parseProgress2Line 解析一行 progress2；不是进度行返回 false
+func parseProgress2Line(line string, p *Progress) bool {
+	m := progress2Re.FindStringSubmatch(line)
+	if m == nil {
+		return false
+	}
+	done, err := strconv.ParseInt(strings.ReplaceAll(m[1], ",", ""), 10, 64)
+	if err != nil {
+		return false
+	}
+	pct, _ := strconv.ParseInt(m[2], 10, 64)
+	rate, _ := strconv.ParseFloat(m[3], 64)
+	switch m[4] {
+	case "k", "K":
+		rate *= 1 << 10
+	case "M":
+		rate *= 1 << 20
+	case "G":
+		rate *= 1 << 30
+	case "T":
+		rate *= 1 << 40
+	}
+	h, _ := strconv.ParseInt(m[5], 10, 64)
+	mi, _ := strconv.ParseInt(m[6], 10, 64)
+	sec, _ := strconv.ParseInt(m[7], 10, 64)
+
+	p.BytesDone = done
+	if pct > 0 {
+		p.BytesTotal = done * 100 / pct
+	}
+	p.Rate = rate
+	p.ETASeconds = h*3600 + mi*60 + sec
+	if m[9] != "" {
+		remaining, _ := strconv.ParseInt(m[9], 10, 64)
+		total, _ := strconv.ParseInt(m[10], 10, 64)
+		p.FilesTotal = total
+		p.FilesDone = total - remaining
+	}
+	p.UpdatedAt = time.Now()
+	return true
+}
+
+// --info=progress2 是 rsync 3.1 加的；更老的 rsync 看到它直接报错退出。
+// 在执行端上跑命令行 rsync 前先查一次版本（每台主机只查一次），老版本不要实时进度，只在结束时用 --stats 的统计。
+// （--progress 是单个文件的进度，拼不出总进度，不用）
+var rsyncVersionRe = regexp.MustCompile(`rsync\s+version\s+v?(\d+)\.(\d+)`)
+
+// rsyncSupportsProgress2 解析 `rsync --version` 的输出
+func rsyncSupportsProgress2(versionOut string) bool {
+	m := rsyncVersionRe.FindStringSubmatch(versionOut)
+	if m == nil {
+		return false
+	}
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+	return major > 3 || major == 3 && minor >= 1
+}
+
+// progressArgs：exec host 上的命令行 rsync 要加的进度参数；sshCli 是已经连上 h 的连接。
+// 探测失败不缓存，这次按老版本处理
+func progressArgs(job *Job, h *Host, sshCli *ssh.Client) []string {
+	h.rsyncMu.Lock()
+	defer h.rsyncMu.Unlock()
+	if h.rsyncProgress2 == nil {
+		sess, err := sshCli.NewSession()
+		if err != nil {
+			job.appendLog("[progress] rsync --version on " + h.Config.Name + " failed: " + err.Error() + "; no live progress")
+			return nil
+		}
+		out, err := sess.CombinedOutput("bash -lc " + shQuote("rsync --version"))
+		_ = sess.Close()
+		if err != nil {
+			job.appendLog("[progress] rsync --version on " + h.Config.Name + " failed: " + err.Error() + "; no live progress")
+			return nil
+		}
+		ok := rsyncSupportsProgress2(string(out))
+		h.rsyncProgress2 = &ok
+		if !ok {
+			first, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+			job.appendLog("[progress] " + h.Config.Name + ": " + first + " is older than 3.1, no live progress")
+		}
+	}
+	if *h.rsyncProgress2 {
+		return []string{"--info=progress2"}
+	}
+	return nil
+}
+
+// previewProgressArgs：预览时不连主机，没探测过就按支持处理
+func previewProgressArgs(h *Host) []string {
+	h.rsyncMu.Lock()
+	defer h.rsyncMu.Unlock()
+	if h.rsyncProgress2 != nil && !*h.rsyncProgress2 {
+		return nil
+	}
+	return []string{"--info=progress2"}
+}
+
+// rsyncOutputWriter：命令行 rsync 的 stdout/stderr。
+// progress2 用 \r 刷新同一行，这里按 \r 和 \n 切分：进度行更新 Job.Progress，其他行照常进日志；
+// --stats 的统计行顺便解析进 stats。
+type rsyncOutputWriter struct {
+	job       *Job
+	out       *jobLineWriter
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	stats     TransferStats
+	haveStats bool
+}
+
+func newRsyncOutputWriter(job *Job, out *jobLineWriter) *rsyncOutputWriter {
+	return &rsyncOutputWriter{job: job, out: out}
+}
+
+func (w *rsyncOutputWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	n, _ := w.buf.Write(b)
+	for {
+		data := w.buf.Bytes()
+		i := bytes.IndexAny(data, "\r\n")
+		if i < 0 {
+			break
+		}
+		line := string(data[:i])
+		w.buf.Next(i + 1)
+		w.handleLine(line)
+	}
+	return n, nil
+}
+
+func (w *rsyncOutputWriter) handleLine(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	w.job.mu.Lock()
+	ok := parseProgress2Line(line, &w.job.Progress)
+	if ok {
+		w.job.notifyLocked()
+	}
+	w.job.mu.Unlock()
+	if ok {
+		return
+	}
+	if parseStatsLine(line, &w.stats) {
+		w.haveStats = true
+	}
+	w.out.appendLine(line)
+}
+
+// Stats：解析到的 --stats 统计；rsync 没输出统计时返回 nil
+func (w *rsyncOutputWriter) Stats() *TransferStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.haveStats {
+		return nil
+	}
+	s := w.stats
+	s.Source = "rsync-cli"
+	return &s
+}
+
+func (w *rsyncOutputWriter) Flush() {
+	w.mu.Lock()
+	rest := w.buf.String()
+	w.buf.Reset()
+	w.mu.Unlock()
+	w.handleLine(strings.TrimRight(rest, "\r\n"))
+	w.out.Flush()
+}
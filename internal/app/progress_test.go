@@ -0,0 +1,86 @@
+package app
+
+import "testing"
+
+func TestParseProgress2Line(t *testing.T) {
+	var p Progress
+	if !parseProgress2Line("    1,048,576  50%    2.00MB/s    0:00:01 (xfr#3, to-chk=7/10)", &p) {
+		t.Fatal("progress line not recognized")
+	}
+	if p.BytesDone != 1048576 || p.BytesTotal != 2097152 {
+		t.Fatalf("bytes = %d/%d", p.BytesDone, p.BytesTotal)
+	}
+	if p.Rate != 2*1024*1024 || p.ETASeconds != 1 {
+		t.Fatalf("rate=%v eta=%d", p.Rate, p.ETASeconds)
+	}
+	if p.FilesDone != 3 || p.FilesTotal != 10 {
+		t.Fatalf("files = %d/%d", p.FilesDone, p.FilesTotal)
+	}
+
+	for _, line := range []string{
+		"sending incremental file list",
+		"rsync: connection unexpectedly closed",
+		"dir/file-100%.txt",
+	} {
+		if parseProgress2Line(line, &p) {
+			t.Errorf("%q parsed as progress", line)
+		}
+	}
+}
+
+func TestRsyncStatsOutput(t *testing.T) {
+	job := &Job{}
+	ow := newRsyncOutputWriter(job, &jobLineWriter{job: job})
+	_, _ = ow.Write([]byte(`
+Number of files: 3 (reg: 2, dir: 1)
+Number of created files: 1 (reg: 1)
+Number of deleted files: 4
+Number of regular files transferred: 2
+Total file size: 2,097,152 bytes
+Total transferred file size: 1,048,576 bytes
+Literal data: 524,288 bytes
+Matched data: 524,288 bytes
+File list size: 0
+Total bytes sent: 525,000
+Total bytes received: 1,234
+
+sent 525,000 bytes  received 1,234 bytes  350,822.67 bytes/sec
+total size is 2,097,152  speedup is 3.99
+`))
+	ow.Flush()
+
+	st := ow.Stats()
+	if st == nil {
+		t.Fatal("stats not parsed")
+	}
+	want := TransferStats{
+		Source: "rsync-cli", FilesTotal: 3, FilesTransferred: 2, FilesCreated: 1, FilesDeleted: 4,
+		TotalFileSize: 2097152, TransferredFileSize: 1048576, LiteralData: 524288, MatchedData: 524288,
+		BytesSent: 525000, BytesReceived: 1234, Speedup: 3.99,
+	}
+	if *st != want {
+		t.Fatalf("stats = %+v", *st)
+	}
+	if job.LogTotal == 0 {
+		t.Fatal("stats lines should still be logged")
+	}
+
+	var s TransferStats
+	if !parseStatsLine("Total bytes sent: 1.50M", &s) || s.BytesSent != 1500000 {
+		t.Fatalf("human-readable bytes = %d", s.BytesSent)
+	}
+}
+
+func TestRsyncSupportsProgress2(t *testing.T) {
+	for out, want := range map[string]bool{
+		"rsync  version 3.2.7  protocol version 31\nCopyright (C) 1996-2022": true,
+		"rsync  version 3.1.0  protocol version 31":                          true,
+		"rsync  version 3.0.9  protocol version 30":                          false,
+		"openrsync: protocol version 29\nrsync version 2.6.9 compatible":     false,
+		"bash: rsync: command not found":                                     false,
+	} {
+		if got := rsyncSupportsProgress2(out); got != want {
+			t.Errorf("rsyncSupportsProgress2(%q) = %v, want %v", out, got, want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package app
+
+import (
+	"context"
+)
+
+// queuedJob：StartJob 之后、拿到执行槽位之前的任务
+type queuedJob struct {
+	job   *Job
+	ctx   context.Context
+	hosts []string // 需要占用槽位的远程主机（source/dest/execHost，去重，不含 local）
+}
+
+// enqueue 按 Priority 从高到低插入，同优先级保持 FIFO
+func (m *JobManager) enqueue(q *queuedJob) {
+	m.queueMu.Lock()
+	prio := q.job.Request.Priority
+	i := len(m.queue)
+	for i > 0 && m.queue[i-1].job.Request.Priority < prio {
+		i--
+	}
+	m.queue = append(m.queue, nil)
+	copy(m.queue[i+1:], m.queue[i:])
+	m.queue[i] = q
+	m.queueMu.Unlock()
+
+	m.dispatch()
+}
+
+// dequeue 把还没开始的任务从队列里拿掉；返回 false 表示它已经被调度走了
+func (m *JobManager) dequeue(job *Job) bool {
+	m.queueMu.Lock()
+	defer m.queueMu.Unlock()
+	for i, q := range m.queue {
+		if q.job == job {
+			m.queue = append(m.queue[:i], m.queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// dispatch 按队列顺序启动所有能拿到槽位的任务。
+// 某个任务的主机满了就跳过它，不阻塞后面去别的主机的任务。
+func (m *JobManager) dispatch() {
+	m.queueMu.Lock()
+	defer m.queueMu.Unlock()
+
+	kept := m.queue[:0]
+	for _, q := range m.queue {
+		if !m.canRunLocked(q) {
+			kept = append(kept, q)
+			continue
+		}
+		m.running++
+		for _, h := range q.hosts {
+			m.hostRunning[h]++
+		}
+		go m.runQueued(q)
+	}
+	for i := len(kept); i < len(m.queue); i++ {
+		m.queue[i] = nil
+	}
+	m.queue = kept
+}
+
+func (m *JobManager) canRunLocked(q *queuedJob) bool {
+	if m.maxRunning > 0 && m.running >= m.maxRunning {
+		return false
+	}
+	for _, name := range q.hosts {
+		h, ok := m.hosts.Get(name)
+		if !ok || h.Config.MaxJobs <= 0 {
+			continue
+		}
+		if m.hostRunning[name] >= h.Config.MaxJobs {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *JobManager) runQueued(q *queuedJob) {
+	defer func() {
+		m.queueMu.Lock()
+		m.running--
+		for _, h := range q.hosts {
+			m.hostRunning[h]--
+			if m.hostRunning[h] <= 0 {
+				delete(m.hostRunning, h)
+			}
+		}
+		m.queueMu.Unlock()
+		m.dispatch()
+	}()
+	if m.runFn != nil {
+		m.runFn(q.ctx, q.job)
+		return
+	}
+	m.runJob(q.ctx, q.job)
+}
+
+// QueueStats：队列/运行中的数量
+type QueueStats struct {
+	Queued      int            `json:"queued"`
+	Running     int            `json:"running"`
+	MaxRunning  int            `json:"maxRunning"`
+	HostRunning map[string]int `json:"hostRunning"`
+}
+
+func (m *JobManager) QueueStats() QueueStats {
+	m.queueMu.Lock()
+	defer m.queueMu.Unlock()
+	hr := make(map[string]int, len(m.hostRunning))
+	for k, v := range m.hostRunning {
+		hr[k] = v
+	}
+	return QueueStats{
+		Queued:      len(m.queue),
+		Running:     m.running,
+		MaxRunning:  m.maxRunning,
+		HostRunning: hr,
+	}
+}
+
+func planSlotHosts(plan *TransferPlan) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, name := range []string{plan.Source.HostName, plan.Dest.HostName, plan.ExecHost} {
+		if name == "" || name == "local" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		out = append(out, name)
+	}
+	return out
+}
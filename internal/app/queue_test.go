@@ -0,0 +1,240 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingRunner：任务拿到槽位后报告名字，然后一直占着槽位，直到被 release 或取消
+type blockingRunner struct {
+	mu      sync.Mutex
+	names   map[string]string // job ID -> 测试里的名字
+	gates   map[string]chan struct{}
+	started chan string
+}
+
+func newBlockingRunner() *blockingRunner {
+	return &blockingRunner{names: make(map[string]string), gates: make(map[string]chan struct{}), started: make(chan string, 64)}
+}
+
+// submit：建任务并交给队列
+func (r *blockingRunner) submit(m *JobManager, name string, req TransferRequest, plan *TransferPlan) *Job {
+	job := m.NewJob(req, plan)
+	r.mu.Lock()
+	r.names[job.ID] = name
+	r.mu.Unlock()
+	m.StartJob(job)
+	return job
+}
+
+func (r *blockingRunner) gate(name string) chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	g, ok := r.gates[name]
+	if !ok {
+		g = make(chan struct{})
+		r.gates[name] = g
+	}
+	return g
+}
+
+func (r *blockingRunner) run(ctx context.Context, job *Job) {
+	r.mu.Lock()
+	name := r.names[job.ID]
+	r.mu.Unlock()
+	r.started <- name
+	status := JobOK
+	select {
+	case <-r.gate(name):
+	case <-ctx.Done():
+		status = JobCancel
+	}
+	job.mu.Lock()
+	job.finishLocked(status)
+	job.mu.Unlock()
+}
+
+func (r *blockingRunner) release(name string) { close(r.gate(name)) }
+
+// wait：等 n 个任务开始，再确认没有多开
+func (r *blockingRunner) wait(t *testing.T, n int) []string {
+	t.Helper()
+	var got []string
+	timeout := time.After(5 * time.Second)
+	for len(got) < n {
+		select {
+		case name := <-r.started:
+			got = append(got, name)
+		case <-timeout:
+			t.Fatalf("started %v, want %d jobs", got, n)
+		}
+	}
+	select {
+	case name := <-r.started:
+		t.Fatalf("started %v and unexpected %s", got, name)
+	case <-time.After(50 * time.Millisecond):
+	}
+	sort.Strings(got)
+	return got
+}
+
+func TestQueueDispatch(t *testing.T) {
+	type jobSpec struct {
+		name  string
+		prio  int
+		hosts []string // source/dest 主机名，不够两个用 local 补
+	}
+	type step struct {
+		release string   // 先结束哪个任务（第一步为空：刚提交完）
+		start   []string // 之后新开始的任务（排序后比较）
+	}
+	for _, tc := range []struct {
+		name    string
+		max     int
+		hostMax map[string]int
+		jobs    []jobSpec
+		steps   []step
+	}{
+		{
+			name: "priority then FIFO",
+			max:  1,
+			jobs: []jobSpec{{name: "first"}, {name: "low1"}, {name: "high", prio: 5}, {name: "low2"}, {name: "mid", prio: 1}},
+			steps: []step{
+				{start: []string{"first"}},
+				{release: "first", start: []string{"high"}},
+				{release: "high", start: []string{"mid"}},
+				{release: "mid", start: []string{"low1"}},
+				{release: "low1", start: []string{"low2"}},
+				{release: "low2"},
+			},
+		},
+		{
+			name:    "full host is skipped",
+			hostMax: map[string]int{"h1": 1},
+			jobs:    []jobSpec{{name: "a", hosts: []string{"h1"}}, {name: "b", hosts: []string{"h1"}, prio: 9}, {name: "c", hosts: []string{"h2"}}},
+			steps: []step{
+				{start: []string{"a", "c"}},
+				{release: "c"},
+				{release: "a", start: []string{"b"}},
+				{release: "b"},
+			},
+		},
+		{
+			name:    "global and host limits together",
+			max:     2,
+			hostMax: map[string]int{"h1": 1, "h2": 2},
+			jobs: []jobSpec{
+				{name: "a", hosts: []string{"h1", "h2"}},
+				{name: "b", hosts: []string{"h1"}},
+				{name: "c", hosts: []string{"h2"}},
+				{name: "d", hosts: []string{"h2"}},
+			},
+			steps: []step{
+				{start: []string{"a", "c"}},
+				{release: "a", start: []string{"b"}},
+				{release: "c", start: []string{"d"}},
+				{release: "b"},
+				{release: "d"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var cfgs []HostConfig
+			for _, h := range []string{"h1", "h2"} {
+				cfgs = append(cfgs, HostConfig{Name: h, Host: h + ".example", MaxJobs: tc.hostMax[h]})
+			}
+			reg, err := NewHostRegistry(cfgs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := NewJobManager(reg, JobManagerOptions{MaxConcurrent: tc.max})
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := newBlockingRunner()
+			m.runFn = r.run
+
+			for _, js := range tc.jobs {
+				ends := append(append([]string(nil), js.hosts...), "local", "local")
+				plan := &TransferPlan{Source: Endpoint{HostName: ends[0]}, Dest: Endpoint{HostName: ends[1]}}
+				r.submit(m, js.name, TransferRequest{Priority: js.prio}, plan)
+			}
+			for i, st := range tc.steps {
+				if st.release != "" {
+					r.release(st.release)
+				}
+				if got := r.wait(t, len(st.start)); len(st.start) > 0 && !reflect.DeepEqual(got, st.start) {
+					t.Fatalf("step %d (release %q): started %v, want %v", i, st.release, got, st.start)
+				}
+			}
+			deadline := time.Now().Add(5 * time.Second)
+			for s := m.QueueStats(); s.Running != 0 || s.Queued != 0 || len(s.HostRunning) != 0; s = m.QueueStats() {
+				if time.Now().After(deadline) {
+					t.Fatalf("slots not released: %+v", s)
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+	}
+}
+
+func TestCancelQueuedJob(t *testing.T) {
+	reg, err := NewHostRegistry(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewJobManager(reg, JobManagerOptions{MaxConcurrent: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newBlockingRunner()
+	m.runFn = r.run
+	plan := &TransferPlan{Source: Endpoint{HostName: "local"}, Dest: Endpoint{HostName: "local"}}
+
+	running := r.submit(m, "running", TransferRequest{}, plan)
+	queued := r.submit(m, "queued", TransferRequest{}, plan)
+	r.wait(t, 1)
+
+	// 排队中的任务直接结束，不占槽位，之后也不会再被调度
+	if _, err := m.CancelJob(queued.ID); err != nil {
+		t.Fatal(err)
+	}
+	if queued.Status != JobCancel {
+		t.Fatalf("queued job status = %s", queued.Status)
+	}
+	if s := m.QueueStats(); s.Queued != 0 || s.Running != 1 {
+		t.Fatalf("queue after cancel = %+v", s)
+	}
+	if _, err := m.CancelJob(queued.ID); !errors.Is(err, ErrJobNotActive) {
+		t.Fatalf("second cancel: %v", err)
+	}
+
+	// 运行中的任务通过 ctx 取消
+	if _, err := m.CancelJob(running.ID); err != nil {
+		t.Fatal(err)
+	}
+	if started := r.wait(t, 0); len(started) != 0 {
+		t.Fatalf("cancelled job was started: %v", started)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		running.mu.Lock()
+		status := running.Status
+		running.mu.Unlock()
+		if status == JobCancel {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("running job status = %s", status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if s := m.QueueStats(); s.Queued != 0 {
+		t.Fatalf("queue after cancel = %+v", s)
+	}
+}
@@ -0,0 +1,168 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================
+// 5) two_step_local：两台远程互相连不通时，经本机中转 A→local→B
+//   - 第一步用 Go-native receiver 把源拉到本机 staging 目录
+//   - 第二步用 Go-native sender 把 staging 推到目标
+//   - 默认结束后删掉 staging；KeepStaging 时按源路径固定 staging 目录，下次只拉增量
+//
+// ============================
+
+// ErrRelayDryRun：经 staging 中转（本机或第三台主机）做不了 dry run——第一步不真拉，第二步就没东西可比；真拉又会把整个源拷进 staging。
+// 两台远程互相连不通时想先看看会传什么，用流式中转（不落盘）
+var ErrRelayDryRun = errors.New(`dry run is not supported when relaying through a staging copy; use execSide "stream" to preview`)
+
+// stagingLocks：同一个缓存目录同时只给一个任务用
+var stagingLocks sync.Map // path -> chan struct{}
+
+func (m *JobManager) stagingBase() string {
+	if m.stagingDir != "" {
+		return m.stagingDir
+	}
+	return filepath.Join(os.TempDir(), "rsyncgui-staging")
+}
+
+// stagingPath：不保留时每个任务一个目录；保留时按源 host:path 固定，方便下次复用
+func (m *JobManager) stagingPath(job *Job) string {
+	if !job.Request.KeepStaging {
+		return filepath.Join(m.stagingBase(), job.ID)
+	}
+	return filepath.Join(m.stagingBase(), stagingCacheName(job.Plan.Source))
+}
+
+// stagingCacheName：保留的 staging 目录名；选择不同的文件不能共用一个缓存，否则旧选择会被带到目标
+func stagingCacheName(src Endpoint) string {
+	key := src.HostName + ":" + src.Path
+	if len(src.Files) > 0 {
+		key += "\x00" + strings.Join(src.Files, "\x00")
+	}
+	sum := sha256.Sum256([]byte(key))
+	return "cache-" + hex.EncodeToString(sum[:8])
+}
+
+// tryLockStaging：不等待；dir 正在被任务使用时返回 false（清理缓存时用）
+func tryLockStaging(dir string) (func(), bool) {
+	v, _ := stagingLocks.LoadOrStore(dir, make(chan struct{}, 1))
+	ch := v.(chan struct{})
+	select {
+	case ch <- struct{}{}:
+		return func() { <-ch }, true
+	default:
+		return nil, false
+	}
+}
+
+// lockStaging：拿到 dir 的独占权；ctx 取消时放弃等待
+func lockStaging(ctx context.Context, dir string) (func(), error) {
+	v, _ := stagingLocks.LoadOrStore(dir, make(chan struct{}, 1))
+	ch := v.(chan struct{})
+	select {
+	case ch <- struct{}{}:
+		return func() { <-ch }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// stagedSourcePath：第二步的源路径，保持和原始源路径一样的“带不带结尾 /”语义；
+// 有文件选择时 staging 里就是选中的那几项，整个推过去
+func stagedSourcePath(stageDir string, src Endpoint) string {
+	if len(src.Files) > 0 || pathHasTrailingSeparator(src.Path) {
+		return stageDir + string(filepath.Separator)
+	}
+	return filepath.Join(stageDir, path.Base(src.Path))
+}
+
+func (m *JobManager) runTwoStepLocal(job *Job, ctx context.Context) error {
+	plan := job.Plan
+	opts := job.Request.Options
+	keep := job.Request.KeepStaging
+	if opts.DryRun {
+		return ErrRelayDryRun
+	}
+
+	stageDir := m.stagingPath(job)
+	if keep {
+		job.appendLog("[two-step] waiting for staging cache " + stageDir)
+	}
+	unlock, err := lockStaging(ctx, stageDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := os.MkdirAll(stageDir, 0o755); err != nil {
+		return fmt.Errorf("create staging dir: %w", err)
+	}
+	if keep {
+		// 目录的修改时间当作缓存最后一次使用的时间，历史清理按它删长期不用的缓存（见 retention.go）
+		now := time.Now()
+		_ = os.Chtimes(stageDir, now, now)
+		job.appendLog("[two-step] staging cache: " + stageDir + " (kept for the next run)")
+	} else {
+		job.appendLog("[two-step] staging dir: " + stageDir)
+		defer func() {
+			if err := os.RemoveAll(stageDir); err != nil {
+				job.appendLog("[two-step] remove staging dir failed: " + err.Error())
+				return
+			}
+			job.appendLog("[two-step] staging dir removed")
+		}()
+	}
+	// 第一步：staging 当作源的镜像，总是 --delete，免得缓存里残留的旧文件被推到目标
+	pullOpts := opts
+	pullOpts.Delete = true
+	staging := Endpoint{HostName: "local", Path: stageDir}
+	job.appendLog(fmt.Sprintf("=== step 1/2: pull %s:%s -> local staging ===", plan.Source.HostName, plan.Source.Path))
+	if err := m.runRemoteToLocal_GoRsync(job, ctx, plan.Source, staging, &pullOpts); err != nil {
+		job.appendLog("=== step 1/2 failed ===")
+		return fmt.Errorf("step 1 (pull to staging): %w", err)
+	}
+	job.mu.Lock()
+	pullStats := job.Stats
+	job.mu.Unlock()
+	job.appendLog("=== step 1/2 done ===")
+
+	job.resetProgress()
+	staged := Endpoint{HostName: "local", Path: stagedSourcePath(stageDir, plan.Source)}
+	job.appendLog(fmt.Sprintf("=== step 2/2: push local staging -> %s:%s ===", plan.Dest.HostName, plan.Dest.Path))
+	if err := m.runLocalToRemote_GoRsync(job, ctx, staged, plan.Dest, &opts); err != nil {
+		job.appendLog("=== step 2/2 failed ===")
+		return fmt.Errorf("step 2 (push from staging): %w", err)
+	}
+	job.mu.Lock()
+	pushStats := job.Stats
+	job.mu.Unlock()
+	job.appendLog("=== step 2/2 done ===")
+
+	job.setStats(mergeRelayStats(pullStats, pushStats))
+	return nil
+}
+
+// mergeRelayStats：文件数/大小以推到目标的那一步为准，线上字节是两步之和（本机的总流量）
+func mergeRelayStats(pull, push *TransferStats) *TransferStats {
+	if push == nil {
+		return pull
+	}
+	s := *push
+	if pull != nil {
+		s.BytesSent += pull.BytesSent
+		s.BytesReceived += pull.BytesReceived
+		s.computeSpeedup()
+	}
+	return &s
+}
@@ -0,0 +1,186 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// ============================
+// 7) via_host：源和目标互相连不通，但第三台主机（跳板/构建机）两边都能连
+//   - execSide 填那台主机的名字；第一跳和 OneHop 一样用 Go SSH，两端需要的 key 一起转发过去
+//   - 第一步在第三台主机上 rsync 把源拉到它的 staging（~/.cache/rsyncgui/staging），第二步再推到目标
+//   - 默认结束后删掉 staging；KeepStaging 时按源路径固定目录，下次只拉增量
+//
+// ============================
+
+const viaHostStagingBase = ".cache/rsyncgui/staging" // 相对 exec host 的 $HOME
+
+// viaHostStagingDir：和本机中转一样，不保留时每个任务一个目录
+func viaHostStagingDir(job *Job) string {
+	if !job.Request.KeepStaging {
+		return path.Join(viaHostStagingBase, job.ID)
+	}
+	return path.Join(viaHostStagingBase, stagingCacheName(job.Plan.Source))
+}
+
+// viaHostCommands：exec host 上两步各自的 rsync 命令（预览和执行共用）；progress 见 progressArgs
+func viaHostCommands(req *TransferRequest, plan *TransferPlan, srcHost, dstHost *Host, stageDir string, progress []string) (pull, push string) {
+	srcDial, srcLan := plan.dial(plan.ExecHost, srcHost)
+	dstDial, dstLan := plan.dial(plan.ExecHost, dstHost)
+
+	// staging 当作源的镜像，总是 --delete（dry run 在计划阶段就拒绝了，见 ErrRelayDryRun）
+	pullOpts := req.Options
+	pullOpts.Delete = true
+	pullArgs := buildRsyncArgs(&pullOpts)
+	pullArgs = appendResumeArgs(pullArgs, &req.Retry)
+	pullArgs = append(pullArgs, "--protect-args", "-e", buildInnerSSHCommand(srcHost.Config, srcDial, srcLan))
+	pullArgs = append(append(pullArgs, progress...), "--stats")
+	srcPath, selArgs, filesFrom := selectionCLI(&pullOpts, plan.Source)
+	pullArgs = append(pullArgs, selArgs...)
+
+	pushArgs := buildRsyncArgs(&req.Options)
+	pushArgs = appendResumeArgs(pushArgs, &req.Retry)
+	pushArgs = append(pushArgs, "--protect-args", "-e", buildInnerSSHCommand(dstHost.Config, dstDial, dstLan))
+	pushArgs = append(append(pushArgs, progress...), "--stats")
+
+	// 第二步的源保持和原始源路径一样的“带不带结尾 /”语义；有选择时 staging 里只有选中的项
+	staged := stageDir + "/"
+	if len(plan.Source.Files) == 0 && !pathHasTrailingSeparator(plan.Source.Path) {
+		staged = path.Join(stageDir, path.Base(plan.Source.Path))
+	}
+
+	pull = rsyncCommandLine(pullArgs, filesFrom, fmt.Sprintf("%s@%s:%s", srcHost.Config.User, srcDial.Host, srcPath), stageDir+"/")
+	push = rsyncCommandLine(pushArgs, "", staged, fmt.Sprintf("%s@%s:%s", dstHost.Config.User, dstDial.Host, plan.Dest.Path))
+	return pull, push
+}
+
+func (m *JobManager) runRemoteToRemote_ViaHost(job *Job, ctx context.Context) error {
+	plan := job.Plan
+	req := job.Request
+	keep := req.KeepStaging
+	if req.Options.DryRun {
+		return ErrRelayDryRun
+	}
+
+	srcHost, err := m.getHost(plan.Source.HostName)
+	if err != nil {
+		return err
+	}
+	dstHost, err := m.getHost(plan.Dest.HostName)
+	if err != nil {
+		return err
+	}
+	execHost, err := m.getHost(plan.ExecHost)
+	if err != nil {
+		return err
+	}
+
+	stageDir := viaHostStagingDir(job)
+	if keep {
+		job.appendLog("[via-host] waiting for staging cache " + execHost.Config.Name + ":~/" + stageDir)
+	}
+	unlock, err := lockStaging(ctx, execHost.Config.Name+":"+stageDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	execDial, execLan := plan.dial("local", execHost)
+	job.appendLog(fmt.Sprintf("[via-host] first hop (control->execHost) %s@%s:%d (LAN=%v)",
+		execHost.Config.User, execDial.Host, execDial.Port, execLan))
+	sshCli, _, err := dialHop(ctx, job, execHost)
+	if err != nil {
+		return err
+	}
+	defer sshCli.Close()
+
+	// 两端需要的 key 一次性转发（同一个连接上只能挂一次 agent）
+	var keys []string
+	for _, h := range []*Host{srcHost, dstHost} {
+		if h.Config.Auth == "private_key" && h.Config.KeyPath != "" {
+			keys = append(keys, h.Config.KeyPath)
+		}
+	}
+	forwardAgent := len(keys) > 0
+	if forwardAgent {
+		if err := forwardKeysToExecHost(sshCli, keys); err != nil {
+			return err
+		}
+	}
+
+	if keep {
+		job.appendLog("[via-host] staging cache: ~/" + stageDir + " (kept for the next run)")
+	} else {
+		job.appendLog("[via-host] staging dir: ~/" + stageDir)
+		defer func() {
+			// ctx 可能已经取消，清理用独立的 context
+			if _, err := runViaHostStep(context.Background(), job, sshCli, false, "rm -rf -- "+shQuote(stageDir)); err != nil {
+				job.appendLog("[via-host] remove staging dir failed: " + err.Error())
+				return
+			}
+			job.appendLog("[via-host] staging dir removed")
+		}()
+	}
+	pull, push := viaHostCommands(&req, &plan, srcHost, dstHost, stageDir, progressArgs(job, execHost, sshCli))
+
+	job.appendLog(fmt.Sprintf("=== step 1/2: pull %s:%s -> %s staging ===", plan.Source.HostName, plan.Source.Path, execHost.Config.Name))
+	pullStats, err := runViaHostStep(ctx, job, sshCli, forwardAgent, "mkdir -p "+shQuote(stageDir)+" && exec "+pull)
+	if err != nil {
+		job.appendLog("=== step 1/2 failed ===")
+		return fmt.Errorf("step 1 (pull to %s): %w", execHost.Config.Name, err)
+	}
+	job.appendLog("=== step 1/2 done ===")
+
+	job.resetProgress()
+	job.appendLog(fmt.Sprintf("=== step 2/2: push %s staging -> %s:%s ===", execHost.Config.Name, plan.Dest.HostName, plan.Dest.Path))
+	pushStats, err := runViaHostStep(ctx, job, sshCli, forwardAgent, "exec "+push)
+	if err != nil {
+		job.appendLog("=== step 2/2 failed ===")
+		return fmt.Errorf("step 2 (push from %s): %w", execHost.Config.Name, err)
+	}
+	job.appendLog("=== step 2/2 done ===")
+
+	job.setStats(mergeRelayStats(pullStats, pushStats))
+	return nil
+}
+
+// runViaHostStep：在 exec host 上开一个 session 跑 cmdStr，输出进任务日志/进度，返回 --stats 的统计
+func runViaHostStep(ctx context.Context, job *Job, sshCli *ssh.Client, forwardAgent bool, cmdStr string) (*TransferStats, error) {
+	sess, err := sshCli.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	defer sess.Close()
+
+	if forwardAgent {
+		if err := agent.RequestAgentForwarding(sess); err != nil {
+			return nil, fmt.Errorf("RequestAgentForwarding: %w", err)
+		}
+	}
+
+	w := &jobLineWriter{job: job}
+	ow := newRsyncOutputWriter(job, w)
+	stdout, _ := sess.StdoutPipe()
+	stderr, _ := sess.StderrPipe()
+
+	job.appendLog("[via-host] " + cmdStr)
+	if err := sess.Start("bash -lc " + shQuote(cmdStr)); err != nil {
+		return nil, fmt.Errorf("start remote command: %w", err)
+	}
+	// 输出读完再取 --stats，免得最后几行还没进 ow
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() { defer wg.Done(); _, _ = io.Copy(ow, stdout) }()
+	go func() { defer wg.Done(); _, _ = io.Copy(w, stderr) }()
+	err = waitSession(ctx, sess)
+	wg.Wait()
+	ow.Flush()
+	w.Flush()
+	return ow.Stats(), err
+}
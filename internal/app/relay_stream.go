@@ -0,0 +1,415 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// ============================
+// 6) relay_stream：两台远程互相连不通时，本机在内存里中转 rsync 协议流（不落盘）
+//   - 源机跑 rsync --server --sender，目标机跑 rsync --server，两条 SSH session
+//   - 两个 server 都以为对面是客户端：版本号、checksum seed、过滤规则这几步由本机代答，
+//     之后把各自多路复用输出里的数据帧转给对方，其余消息（info/error）写进任务日志
+//   - 固定 --protocol=27（和 Go-native 一样）：这个版本 server 的输入不分帧、没有 compat flags，
+//     两边用同一个 --checksum-seed，块校验才对得上
+//
+// ============================
+
+const (
+	relayProtocol = 27
+	mplexBase     = 7 // 多路复用帧头：((mplexBase + tag) << 24) | len
+	msgData       = 0
+)
+
+// relayServerArgs：两端 rsync --server 共用的选项，以及需要走协议发送的过滤规则
+type relayServerArgs struct {
+	Args       []string
+	Filters    []string
+	DstFilters bool // 目标端（receiver）是否也要收过滤规则：rsync 只在 --delete/-m 时发给它
+}
+
+// relayStreamArgs：把用户选项换成 server 端能接受的参数。
+// server 不接受命令行里的 --exclude/--include/--filter，这些转成协议里的过滤规则；只在客户端有意义的选项去掉。
+func relayStreamArgs(opts *RsyncOptions, retry *RetryPolicy) (*relayServerArgs, error) {
+	out := &relayServerArgs{}
+	args := buildRsyncArgs(opts)
+	args = appendResumeArgs(args, retry)
+
+	deleteMode, deleteExcluded, prune := false, false, false
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		// 取 --opt=value 或 --opt value 的值
+		value := func(name string) (string, bool) {
+			if v, ok := strings.CutPrefix(a, name+"="); ok {
+				return v, true
+			}
+			if a == name && i+1 < len(args) {
+				i++
+				return args[i], true
+			}
+			return "", false
+		}
+
+		if v, ok := value("--exclude"); ok {
+			out.Filters = append(out.Filters, "- "+v)
+			continue
+		}
+		if v, ok := value("--include"); ok {
+			out.Filters = append(out.Filters, "+ "+v)
+			continue
+		}
+		if v, ok := value("--filter"); ok {
+			out.Filters = append(out.Filters, v)
+			continue
+		}
+		if a == "-f" && i+1 < len(args) {
+			i++
+			out.Filters = append(out.Filters, args[i])
+			continue
+		}
+		if v, ok := value("--exclude-from"); ok {
+			rules, err := readRelayFilterFile(v, "- ")
+			if err != nil {
+				return nil, err
+			}
+			out.Filters = append(out.Filters, rules...)
+			continue
+		}
+		if v, ok := value("--include-from"); ok {
+			rules, err := readRelayFilterFile(v, "+ ")
+			if err != nil {
+				return nil, err
+			}
+			out.Filters = append(out.Filters, rules...)
+			continue
+		}
+		if _, ok := value("--rsh"); ok {
+			continue
+		}
+		if _, ok := value("--rsync-path"); ok {
+			continue
+		}
+		if a == "-e" && i+1 < len(args) {
+			i++
+			continue
+		}
+		if strings.HasPrefix(a, "--files-from") {
+			return nil, fmt.Errorf("relay_stream does not support %s", a)
+		}
+
+		switch {
+		case a == "--progress", a == "--stats", a == "--human-readable", a == "-h",
+			strings.HasPrefix(a, "--info="), strings.HasPrefix(a, "--protocol="), strings.HasPrefix(a, "--checksum-seed="):
+			continue
+		case a == "-P":
+			a = "--partial"
+		case a == "--delete-excluded":
+			deleteMode, deleteExcluded = true, true
+		case a == "--del", a == "--delete", a == "--delete-before", a == "--delete-during",
+			a == "--delete-after", a == "--delete-delay":
+			deleteMode = true
+		case a == "--prune-empty-dirs":
+			prune = true
+		case shortFlagsHave(a, 'm'):
+			prune = true
+		}
+		out.Args = append(out.Args, a)
+	}
+	out.DstFilters = prune || (deleteMode && !deleteExcluded)
+	return out, nil
+}
+
+// shortFlagsHave：短选项组（如 -avm）里有没有 flag；遇到带值的短选项（-T/tmp、-M...）后面都是值
+func shortFlagsHave(a string, flag rune) bool {
+	if len(a) < 2 || a[0] != '-' || a[1] == '-' {
+		return false
+	}
+	for _, c := range a[1:] {
+		if strings.ContainsRune("BefMT@", c) {
+			return false
+		}
+		if c == flag {
+			return true
+		}
+	}
+	return false
+}
+
+func readRelayFilterFile(path, prefix string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read filter file: %w", err)
+	}
+	var rules []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		rules = append(rules, prefix+line)
+	}
+	return rules, nil
+}
+
+func readRsyncInt(r io.Reader) (int32, error) {
+	var b [4]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+	return int32(binary.LittleEndian.Uint32(b[:])), nil
+}
+
+func writeRsyncInt(w io.Writer, v int32) error {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], uint32(v))
+	_, err := w.Write(b[:])
+	return err
+}
+
+// relayHandshake：扮演客户端和一端 server 握手（交换版本、读 seed、发过滤规则），返回 server 的 seed
+func relayHandshake(r io.Reader, w io.Writer, filters []string, sendFilters bool) (int32, error) {
+	if err := writeRsyncInt(w, relayProtocol); err != nil {
+		return 0, fmt.Errorf("send protocol version: %w", err)
+	}
+	ver, err := readRsyncInt(r)
+	if err != nil {
+		return 0, fmt.Errorf("read protocol version: %w", err)
+	}
+	if ver < relayProtocol {
+		return 0, fmt.Errorf("remote rsync speaks protocol %d, need >= %d", ver, relayProtocol)
+	}
+	seed, err := readRsyncInt(r)
+	if err != nil {
+		return 0, fmt.Errorf("read checksum seed: %w", err)
+	}
+	if !sendFilters {
+		return seed, nil
+	}
+	for _, rule := range filters {
+		if err := writeRsyncInt(w, int32(len(rule))); err != nil {
+			return 0, fmt.Errorf("send filter list: %w", err)
+		}
+		if _, err := io.WriteString(w, rule); err != nil {
+			return 0, fmt.Errorf("send filter list: %w", err)
+		}
+	}
+	if err := writeRsyncInt(w, 0); err != nil {
+		return 0, fmt.Errorf("send filter list: %w", err)
+	}
+	return seed, nil
+}
+
+// relayFrames：拆开一端 server 的多路复用输出，数据帧原样写给另一端，其余消息进日志。
+// 另一端先退出时（比如源端最后发的统计对方不会读）继续读完，别把这一端堵住。
+func relayFrames(from io.Reader, to io.Writer, log *jobLineWriter, onData func(int)) error {
+	br := bufio.NewReaderSize(from, 64*1024)
+	payload := make([]byte, 0, 64*1024)
+	for {
+		h, err := readRsyncInt(br)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("read frame header: %w", err)
+		}
+		tag := int(uint32(h)>>24) - mplexBase
+		n := int(uint32(h) & 0xFFFFFF)
+		if tag < 0 {
+			return fmt.Errorf("unexpected non-multiplexed data (header %#08x)", uint32(h))
+		}
+		payload = payload[:0]
+		if cap(payload) < n {
+			payload = make([]byte, 0, n)
+		}
+		payload = payload[:n]
+		if _, err := io.ReadFull(br, payload); err != nil {
+			return fmt.Errorf("read frame: %w", err)
+		}
+
+		if tag != msgData {
+			for _, line := range strings.Split(strings.TrimRight(string(payload), "\n"), "\n") {
+				if line != "" {
+					log.appendLine(line)
+				}
+			}
+			continue
+		}
+		if n == 0 || to == nil {
+			continue
+		}
+		if _, err := to.Write(payload); err != nil {
+			log.appendLine("peer stopped reading: " + err.Error())
+			to = nil
+			continue
+		}
+		if onData != nil {
+			onData(n)
+		}
+	}
+}
+
+// relayEnd：一端的 SSH 连接和 rsync --server 进程
+type relayEnd struct {
+	cli    *ssh.Client
+	sess   *ssh.Session
+	stdin  io.WriteCloser
+	stdout io.Reader
+	log    *jobLineWriter
+}
+
+func (e *relayEnd) close() {
+	if e.sess != nil {
+		_ = e.sess.Close()
+	}
+	if e.cli != nil {
+		_ = e.cli.Close()
+	}
+}
+
+func startRelayEnd(ctx context.Context, job *Job, h *Host, label string, serverArgs []string) (*relayEnd, error) {
+	e := &relayEnd{log: &jobLineWriter{job: job, prefix: label}}
+
+	cli, d, err := dialHop(ctx, job, h)
+	if err != nil {
+		return nil, err
+	}
+	e.cli = cli
+	if e.sess, err = cli.NewSession(); err != nil {
+		e.close()
+		return nil, err
+	}
+	if e.stdin, err = e.sess.StdinPipe(); err != nil {
+		e.close()
+		return nil, err
+	}
+	if e.stdout, err = e.sess.StdoutPipe(); err != nil {
+		e.close()
+		return nil, err
+	}
+	e.sess.Stderr = e.log
+
+	remoteCmd := "cd ~ 2>/dev/null && exec rsync " + joinShellArgs(serverArgs)
+	job.appendLog(fmt.Sprintf("%sssh %s@%s:%d  %s", label, h.Config.User, d.Host, d.Port, remoteCmd))
+	if err := e.sess.Start("sh -c " + shQuote(remoteCmd)); err != nil {
+		e.close()
+		return nil, fmt.Errorf("start remote rsync server: %w", err)
+	}
+	return e, nil
+}
+
+// relayServerCommands：源端 / 目标端的 rsync --server 参数
+func relayServerCommands(plan *TransferPlan, sa *relayServerArgs, seed string) (src, dst []string) {
+	common := []string{fmt.Sprintf("--protocol=%d", relayProtocol), "--checksum-seed=" + seed}
+	common = append(common, sa.Args...)
+	src = append(append([]string{"--server", "--sender"}, common...), ".")
+	src = append(src, plan.Source.sourcePaths()...)
+	dst = append(append([]string{"--server"}, common...), ".", plan.Dest.Path)
+	return src, dst
+}
+
+func (m *JobManager) runRemoteToRemote_RelayStream(job *Job, ctx context.Context) error {
+	plan := job.Plan
+
+	sa, err := relayStreamArgs(&job.Request.Options, &job.Request.Retry)
+	if err != nil {
+		return err
+	}
+	srcHost, err := m.getHost(plan.Source.HostName)
+	if err != nil {
+		return err
+	}
+	dstHost, err := m.getHost(plan.Dest.HostName)
+	if err != nil {
+		return err
+	}
+
+	seed := rand.Int32N(1<<30) + 1 // 0 表示让 server 自己选，不能用
+	srcArgs, dstArgs := relayServerCommands(&plan, sa, strconv.Itoa(int(seed)))
+
+	src, err := startRelayEnd(ctx, job, srcHost, "[relay src] ", srcArgs)
+	if err != nil {
+		return fmt.Errorf("source: %w", err)
+	}
+	defer src.close()
+	dst, err := startRelayEnd(ctx, job, dstHost, "[relay dst] ", dstArgs)
+	if err != nil {
+		return fmt.Errorf("dest: %w", err)
+	}
+	defer dst.close()
+
+	stopSrc := closeSessionOnCancel(ctx, src.sess)
+	defer stopSrc()
+	stopDst := closeSessionOnCancel(ctx, dst.sess)
+	defer stopDst()
+
+	srcSeed, err := relayHandshake(src.stdout, src.stdin, sa.Filters, true)
+	if err != nil {
+		return fmt.Errorf("source handshake: %w", err)
+	}
+	dstSeed, err := relayHandshake(dst.stdout, dst.stdin, sa.Filters, sa.DstFilters)
+	if err != nil {
+		return fmt.Errorf("dest handshake: %w", err)
+	}
+	if srcSeed != seed || dstSeed != seed {
+		return fmt.Errorf("checksum seed mismatch (src=%d dst=%d want=%d); remote rsync ignored --checksum-seed", srcSeed, dstSeed, seed)
+	}
+	job.appendLog(fmt.Sprintf("[relay] handshake ok (protocol %d, %d filter rules); streaming through this machine", relayProtocol, len(sa.Filters)))
+
+	var (
+		wg             sync.WaitGroup
+		sent, received int64
+		pumpErr        [2]error
+	)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		pumpErr[0] = relayFrames(src.stdout, dst.stdin, src.log, func(n int) {
+			sent += int64(n)
+			job.addProgressBytes(int64(n))
+		})
+		_ = dst.stdin.Close()
+	}()
+	go func() {
+		defer wg.Done()
+		pumpErr[1] = relayFrames(dst.stdout, src.stdin, dst.log, func(n int) { received += int64(n) })
+		_ = src.stdin.Close()
+	}()
+
+	srcErr := waitSession(ctx, src.sess)
+	if srcErr != nil {
+		_ = dst.sess.Close() // 源端失败，目标端不会再收到数据
+	}
+	dstErr := waitSession(ctx, dst.sess)
+	if dstErr != nil {
+		_ = src.sess.Close()
+	}
+	wg.Wait()
+	src.log.Flush()
+	dst.log.Flush()
+
+	st := &TransferStats{Source: "relay-stream", BytesSent: sent, BytesReceived: received}
+	job.setStats(st)
+
+	switch {
+	case srcErr != nil:
+		return fmt.Errorf("source rsync server: %w", srcErr)
+	case dstErr != nil:
+		return fmt.Errorf("dest rsync server: %w", dstErr)
+	case pumpErr[0] != nil:
+		return fmt.Errorf("relay source -> dest: %w", pumpErr[0])
+	case pumpErr[1] != nil:
+		return fmt.Errorf("relay dest -> source: %w", pumpErr[1])
+	}
+	return nil
+}
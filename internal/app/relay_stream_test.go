@@ -0,0 +1,104 @@
+package app
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestRelayStreamArgs(t *testing.T) {
+	sa, err := relayStreamArgs(&RsyncOptions{
+		Archive:   true,
+		Delete:    true,
+		ExtraArgs: []string{"--progress", "--exclude=*.tmp", "--include", "keep/", "-P", "--info=progress2", "-e", "ssh -p 2222"},
+	}, &RetryPolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"-a", "--delete", "--partial"}; !reflect.DeepEqual(sa.Args, want) {
+		t.Fatalf("args = %q, want %q", sa.Args, want)
+	}
+	if want := []string{"- *.tmp", "+ keep/"}; !reflect.DeepEqual(sa.Filters, want) {
+		t.Fatalf("filters = %q, want %q", sa.Filters, want)
+	}
+	if !sa.DstFilters {
+		t.Fatal("receiver should get the filter list with --delete")
+	}
+
+	// 只是长得像 --delete / -m 的选项不算
+	for _, extra := range [][]string{{"--delay-updates"}, {"--delete-missing-args"}, {"-T/tmp/m"}, {"-M--fake-super"}} {
+		sa, err := relayStreamArgs(&RsyncOptions{Archive: true, ExtraArgs: extra}, &RetryPolicy{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sa.DstFilters {
+			t.Fatalf("%q: receiver should not get the filter list", extra)
+		}
+	}
+	for _, extra := range [][]string{{"--delete-after"}, {"--del"}, {"-rm"}} {
+		sa, err := relayStreamArgs(&RsyncOptions{ExtraArgs: extra}, &RetryPolicy{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !sa.DstFilters {
+			t.Fatalf("%q: receiver should get the filter list", extra)
+		}
+	}
+
+	if _, err := relayStreamArgs(&RsyncOptions{ExtraArgs: []string{"--files-from=list"}}, &RetryPolicy{}); err == nil {
+		t.Fatal("--files-from should be rejected")
+	}
+}
+
+func frame(tag int, payload string) []byte {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], uint32(mplexBase+tag)<<24|uint32(len(payload)))
+	return append(b[:], payload...)
+}
+
+func TestRelayHandshakeAndFrames(t *testing.T) {
+	// server 那边：版本 + seed，然后是多路复用的输出
+	var server bytes.Buffer
+	_ = writeRsyncInt(&server, 31)
+	_ = writeRsyncInt(&server, 12345)
+	server.Write(frame(msgData, "flist"))
+	server.Write(frame(2, "file.txt\n")) // MSG_INFO
+	server.Write(frame(msgData, ""))
+	server.Write(frame(msgData, "-data"))
+
+	var toServer bytes.Buffer
+	seed, err := relayHandshake(&server, &toServer, []string{"- *.tmp"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seed != 12345 {
+		t.Fatalf("seed = %d", seed)
+	}
+	var want bytes.Buffer
+	_ = writeRsyncInt(&want, relayProtocol)
+	_ = writeRsyncInt(&want, 7)
+	want.WriteString("- *.tmp")
+	_ = writeRsyncInt(&want, 0)
+	if !bytes.Equal(toServer.Bytes(), want.Bytes()) {
+		t.Fatalf("handshake wrote %q, want %q", toServer.Bytes(), want.Bytes())
+	}
+
+	job := &Job{}
+	var peer bytes.Buffer
+	n := 0
+	if err := relayFrames(&server, &peer, &jobLineWriter{job: job, prefix: "[src] "}, func(k int) { n += k }); err != nil {
+		t.Fatal(err)
+	}
+	if peer.String() != "flist-data" || n != len("flist-data") {
+		t.Fatalf("forwarded %q (%d bytes)", peer.String(), n)
+	}
+	if len(job.LogLines) != 1 || job.LogLines[0] != "[src] file.txt" {
+		t.Fatalf("log = %q", job.LogLines)
+	}
+
+	if err := relayFrames(bytes.NewReader([]byte("oops")), io.Discard, &jobLineWriter{job: job}, nil); err == nil {
+		t.Fatal("non-multiplexed input should fail")
+	}
+}
@@ -232,12 +232,12 @@ func scpSendLocalPath(ctx context.Context, sshCli *ssh.Client, localPath, remote
 			return err
 		}
 		for _, entry := range entries {
-			if err := scpSendPathEntry(stdin, ack, filepath.Join(localPath, entry.Name())); err != nil {
+			if err := scpSendPathEntry(stdin, ack, filepath.Join(localPath, entry.Name()), w.job); err != nil {
 				_ = sess.Close()
 				return err
 			}
 		}
-	} else if err := scpSendPathEntry(stdin, ack, localPath); err != nil {
+	} else if err := scpSendPathEntry(stdin, ack, localPath, w.job); err != nil {
 		_ = sess.Close()
 		return err
 	}
@@ -273,7 +273,7 @@ func tarGzipSendLocalPath(ctx context.Context, sshCli *ssh.Client, localPath, re
 		return fmt.Errorf("start remote tar sink: %w", err)
 	}
 
-	writeErr := writeTarGzipPath(stdin, localPath, sendContents)
+	writeErr := writeTarGzipPath(stdin, localPath, sendContents, w.job)
 	closeErr := stdin.Close()
 	if writeErr != nil {
 		_ = sess.Close()
@@ -286,7 +286,8 @@ func tarGzipSendLocalPath(ctx context.Context, sshCli *ssh.Client, localPath, re
 	return waitSession(ctx, sess)
 }
 
-func writeTarGzipPath(dst io.Writer, localPath string, sendContents bool) error {
+// job 非 nil 时按未压缩的文件字节更新进度
+func writeTarGzipPath(dst io.Writer, localPath string, sendContents bool, job *Job) error {
 	gzw := gzip.NewWriter(dst)
 	tw := tar.NewWriter(gzw)
 
@@ -311,7 +312,7 @@ func writeTarGzipPath(dst io.Writer, localPath string, sendContents bool) error
 			return err
 		}
 		for _, entry := range entries {
-			if err := writeTarPathEntry(tw, filepath.Join(localPath, entry.Name()), entry.Name()); err != nil {
+			if err := writeTarPathEntry(tw, filepath.Join(localPath, entry.Name()), entry.Name(), job); err != nil {
 				_ = closeWriters()
 				return err
 			}
@@ -319,14 +320,14 @@ func writeTarGzipPath(dst io.Writer, localPath string, sendContents bool) error
 		return closeWriters()
 	}
 
-	if err := writeTarPathEntry(tw, localPath, filepath.Base(localPath)); err != nil {
+	if err := writeTarPathEntry(tw, localPath, filepath.Base(localPath), job); err != nil {
 		_ = closeWriters()
 		return err
 	}
 	return closeWriters()
 }
 
-func writeTarPathEntry(tw *tar.Writer, path, archiveName string) error {
+func writeTarPathEntry(tw *tar.Writer, path, archiveName string, job *Job) error {
 	info, err := os.Lstat(path)
 	if err != nil {
 		return err
@@ -360,7 +361,7 @@ func writeTarPathEntry(tw *tar.Writer, path, archiveName string) error {
 		}
 		for _, entry := range entries {
 			childArchiveName := filepath.ToSlash(filepath.Join(archiveName, entry.Name()))
-			if err := writeTarPathEntry(tw, filepath.Join(path, entry.Name()), childArchiveName); err != nil {
+			if err := writeTarPathEntry(tw, filepath.Join(path, entry.Name()), childArchiveName, job); err != nil {
 				return err
 			}
 		}
@@ -373,11 +374,19 @@ func writeTarPathEntry(tw *tar.Writer, path, archiveName string) error {
 	}
 	defer f.Close()
 
-	_, err = io.Copy(tw, f)
-	return err
+	if job == nil {
+		_, err = io.Copy(tw, f)
+		return err
+	}
+	if _, err := io.Copy(tw, &progressReader{r: f, job: job}); err != nil {
+		return err
+	}
+	job.addProgressFile(path)
+	return nil
 }
 
-func scpSendPathEntry(dst io.Writer, ack *bufio.Reader, path string) error {
+// job 非 nil 时按文件字节更新进度
+func scpSendPathEntry(dst io.Writer, ack *bufio.Reader, path string, job *Job) error {
 	info, err := os.Stat(path)
 	if err != nil {
 		return err
@@ -401,7 +410,7 @@ func scpSendPathEntry(dst io.Writer, ack *bufio.Reader, path string) error {
 			return err
 		}
 		for _, entry := range entries {
-			if err := scpSendPathEntry(dst, ack, filepath.Join(path, entry.Name())); err != nil {
+			if err := scpSendPathEntry(dst, ack, filepath.Join(path, entry.Name()), job); err != nil {
 				return err
 			}
 		}
@@ -428,13 +437,23 @@ func scpSendPathEntry(dst io.Writer, ack *bufio.Reader, path string) error {
 	}
 	defer f.Close()
 
-	if _, err := io.Copy(dst, f); err != nil {
+	var src io.Reader = f
+	if job != nil {
+		src = &progressReader{r: f, job: job}
+	}
+	if _, err := io.Copy(dst, src); err != nil {
 		return err
 	}
 	if _, err := dst.Write([]byte{0}); err != nil {
 		return err
 	}
-	return readScpAck(ack)
+	if err := readScpAck(ack); err != nil {
+		return err
+	}
+	if job != nil {
+		job.addProgressFile(path)
+	}
+	return nil
 }
 
 func readScpAck(r *bufio.Reader) error {
@@ -477,7 +496,7 @@ func waitSession(ctx context.Context, sess *ssh.Session) error {
 	case err := <-done:
 		return err
 	case <-ctx.Done():
-		_ = sess.Close()
+		terminateSession(sess)
 		err := <-done
 		if err != nil {
 			return fmt.Errorf("%w; session closed with: %v", ctx.Err(), err)
@@ -491,7 +510,7 @@ func closeSessionOnCancel(ctx context.Context, sess *ssh.Session) func() {
 	go func() {
 		select {
 		case <-ctx.Done():
-			_ = sess.Close()
+			terminateSession(sess)
 		case <-done:
 		}
 	}()
@@ -500,8 +519,12 @@ func closeSessionOnCancel(ctx context.Context, sess *ssh.Session) func() {
 	}
 }
 
+// terminateSession 先请求远端进程 SIGTERM（OpenSSH 7.9+ 支持），再关闭 channel
+func terminateSession(sess *ssh.Session) {
+	_ = sess.Signal(ssh.SIGTERM)
+	_ = sess.Close()
+}
+
 func (w *jobLineWriter) appendLine(line string) {
-	w.job.mu.Lock()
-	w.job.LogLines = append(w.job.LogLines, line)
-	w.job.mu.Unlock()
+	w.job.appendLog(w.prefix + line)
 }
@@ -0,0 +1,233 @@
+package app
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ===== 任务历史保留策略：后台定期清理旧任务和它们的日志文件 =====
+
+const pruneInterval = time.Hour
+
+// RetentionPolicy：0 表示不按这一项清理。
+// 失败的任务（failed / interrupted）按 FailedMaxAge 计，并且在 FailedMaxAge 之内不算进 MaxJobs，方便事后排查。
+type RetentionPolicy struct {
+	MaxAge       time.Duration // 结束超过这么久的任务删除
+	MaxJobs      int           // 最多保留这么多个已结束的任务，超出的从最旧的删
+	FailedMaxAge time.Duration // 失败任务的保留时长，0 = 和 MaxAge 一样
+}
+
+func (p RetentionPolicy) enabled() bool {
+	return p.MaxAge > 0 || p.MaxJobs > 0 || p.FailedMaxAge > 0
+}
+
+// PruneResult：一次清理的结果
+type PruneResult struct {
+	Deleted        []string `json:"deleted"`
+	Remaining      int      `json:"remaining"`
+	Pipelines      []string `json:"pipelines,omitempty"`      // 删掉的流水线记录
+	StagingRemoved []string `json:"stagingRemoved,omitempty"` // 删掉的本机 staging 缓存目录
+	DryRun         bool     `json:"dryRun,omitempty"`
+}
+
+func isFailedStatus(s JobStatus) bool {
+	return s == JobFailed || s == JobInterrupted
+}
+
+// recordExpired：流水线这类汇总记录只按时长清理（MaxJobs 只管任务），失败的按 FailedMaxAge 计
+func (p RetentionPolicy) recordExpired(status JobStatus, ended, now time.Time) bool {
+	maxAge := p.MaxAge
+	if isFailedStatus(status) && p.FailedMaxAge > 0 {
+		maxAge = p.FailedMaxAge
+	}
+	return status.IsFinished() && maxAge > 0 && now.Sub(ended) > maxAge
+}
+
+// Retention：当前的保留策略
+func (m *JobManager) Retention() RetentionPolicy {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.retention
+}
+
+// PruneJobs 按保留策略删除旧任务（内存、store 里的记录和日志文件）；
+// 未结束的任务不会删。dryRun 只返回会删哪些。
+func (m *JobManager) PruneJobs(now time.Time, dryRun bool) PruneResult {
+	m.mu.Lock()
+	victims := m.pruneCandidatesLocked(now)
+	if !dryRun && len(victims) > 0 {
+		drop := make(map[*Job]bool, len(victims))
+		for _, job := range victims {
+			drop[job] = true
+			delete(m.jobs, job.ID)
+		}
+		kept := m.order[:0]
+		for _, job := range m.order {
+			if !drop[job] {
+				kept = append(kept, job)
+			}
+		}
+		clear(m.order[len(kept):])
+		m.order = kept
+	}
+	res := PruneResult{Deleted: make([]string, 0, len(victims)), Remaining: len(m.order), DryRun: dryRun}
+	if dryRun {
+		res.Remaining -= len(victims)
+	}
+	m.mu.Unlock()
+
+	for _, job := range victims {
+		res.Deleted = append(res.Deleted, job.ID)
+		if dryRun {
+			continue
+		}
+		if m.store != nil {
+			if err := m.store.Delete(job.ID); err != nil {
+				log.Printf("[retention] delete job %s: %v", job.ID, err)
+			}
+		}
+		job.mu.Lock()
+		path := job.logSink.path
+		job.logSink.close()
+		job.mu.Unlock()
+		if path != "" {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				log.Printf("[retention] remove log %s: %v", path, err)
+			}
+		}
+	}
+	if m.prunePipelines != nil && m.Retention().enabled() {
+		res.Pipelines = m.prunePipelines(now, m.Retention(), dryRun)
+	}
+	res.StagingRemoved = m.pruneStagingCaches(now, dryRun)
+	return res
+}
+
+// pruneStagingCaches：KeepStaging 留在本机的 staging 缓存（stagingBase()/cache-*），超过 MaxAge 没用过就删；
+// 正在被任务使用的跳过。MaxAge 为 0 时不删
+func (m *JobManager) pruneStagingCaches(now time.Time, dryRun bool) []string {
+	maxAge := m.Retention().MaxAge
+	if maxAge <= 0 {
+		return nil
+	}
+	base := m.stagingBase()
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("[retention] read staging dir %s: %v", base, err)
+		}
+		return nil
+	}
+	var removed []string
+	for _, e := range entries {
+		if !e.IsDir() || !strings.HasPrefix(e.Name(), "cache-") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || now.Sub(info.ModTime()) <= maxAge {
+			continue
+		}
+		dir := filepath.Join(base, e.Name())
+		unlock, ok := tryLockStaging(dir)
+		if !ok {
+			continue
+		}
+		if !dryRun {
+			if err := os.RemoveAll(dir); err != nil {
+				log.Printf("[retention] remove staging cache %s: %v", dir, err)
+				unlock()
+				continue
+			}
+		}
+		unlock()
+		removed = append(removed, dir)
+	}
+	return removed
+}
+
+// pruneCandidatesLocked：从最旧的开始挑要删的任务；调用方需持有 m.mu
+func (m *JobManager) pruneCandidatesLocked(now time.Time) []*Job {
+	p := m.retention
+	if !p.enabled() {
+		return nil
+	}
+	failedMaxAge := p.FailedMaxAge
+	if failedMaxAge == 0 {
+		failedMaxAge = p.MaxAge
+	}
+
+	type finished struct {
+		job       *Job
+		age       time.Duration
+		maxAge    time.Duration
+		protected bool // 保护期内的失败任务：不算进 MaxJobs
+	}
+	var done []finished
+	counted := 0
+	for _, job := range m.order {
+		job.mu.Lock()
+		status, ended := job.Status, job.EndedAt
+		if ended.IsZero() {
+			ended = job.CreatedAt
+		}
+		job.mu.Unlock()
+		if !status.IsFinished() {
+			continue
+		}
+		f := finished{job: job, age: now.Sub(ended), maxAge: p.MaxAge}
+		if isFailedStatus(status) {
+			f.maxAge = failedMaxAge
+			f.protected = p.FailedMaxAge > 0 && f.age <= p.FailedMaxAge
+		}
+		if !f.protected {
+			counted++
+		}
+		done = append(done, f)
+	}
+
+	// 超出 MaxJobs 的个数：从最旧的开始删
+	excess := 0
+	if p.MaxJobs > 0 && counted > p.MaxJobs {
+		excess = counted - p.MaxJobs
+	}
+
+	var victims []*Job
+	for _, f := range done {
+		expired := f.maxAge > 0 && f.age > f.maxAge
+		if !expired && (excess == 0 || f.protected) {
+			continue
+		}
+		victims = append(victims, f.job)
+		if excess > 0 && !f.protected {
+			excess--
+		}
+	}
+	return victims
+}
+
+// RunPruner 启动时清理一次，之后每小时清理一次，直到 ctx 结束；策略为空时直接返回
+func (m *JobManager) RunPruner(ctx context.Context) {
+	if !m.Retention().enabled() {
+		return
+	}
+	t := time.NewTicker(pruneInterval)
+	defer t.Stop()
+	for {
+		res := m.PruneJobs(time.Now(), false)
+		if len(res.Deleted) > 0 {
+			log.Printf("[retention] pruned %d jobs, %d remaining", len(res.Deleted), res.Remaining)
+		}
+		if len(res.StagingRemoved) > 0 {
+			log.Printf("[retention] removed %d unused staging caches", len(res.StagingRemoved))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
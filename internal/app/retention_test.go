@@ -0,0 +1,77 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPruneJobs(t *testing.T) {
+	now := time.Now()
+	staging := t.TempDir()
+	m, err := NewJobManager(nil, JobManagerOptions{
+		Retention:  RetentionPolicy{MaxAge: 24 * time.Hour, FailedMaxAge: 72 * time.Hour, MaxJobs: 2},
+		StagingDir: staging,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// staging 缓存：一个很久没用，一个刚用过，一个很久没用但正被任务占着
+	for _, name := range []string{"cache-old", "cache-new", "cache-busy"} {
+		if err := os.MkdirAll(filepath.Join(staging, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"cache-old", "cache-busy"} {
+		old := now.Add(-48 * time.Hour)
+		if err := os.Chtimes(filepath.Join(staging, name), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	unlock, _ := tryLockStaging(filepath.Join(staging, "cache-busy"))
+	defer unlock()
+	add := func(id string, status JobStatus, age time.Duration) {
+		job := &Job{ID: id, Status: status, CreatedAt: now.Add(-age - time.Minute), EndedAt: now.Add(-age)}
+		m.jobs[id] = job
+		m.insertOrderLocked(job)
+	}
+	add("old-ok", JobOK, 48*time.Hour)         // 超过 MaxAge
+	add("old-failed", JobFailed, 48*time.Hour) // 失败任务还在保护期
+	add("ok-1", JobOK, 3*time.Hour)            // 超出 MaxJobs（保护期内的失败任务不算数）
+	add("failed-1", JobFailed, 2*time.Hour)
+	add("ok-2", JobOK, time.Hour)
+	add("ok-3", JobOK, time.Minute)
+	add("running", JobRunning, 0)
+
+	dry := m.PruneJobs(now, true)
+	if len(m.jobs) != 7 || !dry.DryRun {
+		t.Fatalf("dry run removed jobs: %d left", len(m.jobs))
+	}
+
+	res := m.PruneJobs(now, false)
+	want := []string{"old-ok", "ok-1"}
+	if len(res.Deleted) != len(want) {
+		t.Fatalf("deleted = %v, want %v", res.Deleted, want)
+	}
+	for i, id := range want {
+		if res.Deleted[i] != id {
+			t.Fatalf("deleted = %v, want %v", res.Deleted, want)
+		}
+		if _, ok := m.GetJob(id); ok {
+			t.Errorf("job %s still present", id)
+		}
+	}
+	if res.Remaining != 5 || len(m.order) != 5 {
+		t.Fatalf("remaining = %d, order = %d", res.Remaining, len(m.order))
+	}
+
+	if len(dry.StagingRemoved) != 1 || len(res.StagingRemoved) != 1 || filepath.Base(res.StagingRemoved[0]) != "cache-old" {
+		t.Fatalf("staging removed = %v (dry run %v)", res.StagingRemoved, dry.StagingRemoved)
+	}
+	for name, want := range map[string]bool{"cache-old": false, "cache-new": true, "cache-busy": true} {
+		if _, err := os.Stat(filepath.Join(staging, name)); (err == nil) != want {
+			t.Errorf("%s exists = %v, want %v", name, err == nil, want)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// RetryPolicy：传输失败后的自动重试（只重试网络类的临时错误）
+type RetryPolicy struct {
+	MaxAttempts    int    `json:"maxAttempts" yaml:"maxAttempts"`       // 总尝试次数（含第一次），<=1 表示不重试
+	BackoffSeconds int    `json:"backoffSeconds" yaml:"backoffSeconds"` // 第一次重试前等待，之后每次翻倍
+	MaxBackoff     int    `json:"maxBackoff" yaml:"maxBackoff"`         // 等待上限（秒），0 = 5 分钟
+	PartialDir     string `json:"partialDir" yaml:"partialDir"`         // 断点续传目录，默认 .rsync-partial
+}
+
+const (
+	defaultRetryBackoff = 5 * time.Second
+	defaultRetryMaxWait = 5 * time.Minute
+	defaultPartialDir   = ".rsync-partial"
+)
+
+// JobAttempt：一次执行尝试
+type JobAttempt struct {
+	Attempt   int       `json:"attempt"`
+	StartedAt time.Time `json:"startedAt"`
+	EndedAt   time.Time `json:"endedAt"`
+	Error     string    `json:"error,omitempty"`
+	Transient bool      `json:"transient,omitempty"` // 错误被判定为可重试
+}
+
+func (p *RetryPolicy) enabled() bool {
+	return p.MaxAttempts > 1
+}
+
+func (p *RetryPolicy) attempts() int {
+	if p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// backoff：第 n 次失败后的等待时间（n 从 1 开始）
+func (p *RetryPolicy) backoff(n int) time.Duration {
+	d := defaultRetryBackoff
+	if p.BackoffSeconds > 0 {
+		d = time.Duration(p.BackoffSeconds) * time.Second
+	}
+	maxWait := defaultRetryMaxWait
+	if p.MaxBackoff > 0 {
+		maxWait = time.Duration(p.MaxBackoff) * time.Second
+	}
+	for i := 1; i < n && d < maxWait; i++ {
+		d *= 2
+	}
+	if d > maxWait {
+		d = maxWait
+	}
+	return d
+}
+
+func (p *RetryPolicy) partialDir() string {
+	if p.PartialDir != "" {
+		return p.PartialDir
+	}
+	return defaultPartialDir
+}
+
+// appendResumeArgs：开启重试时每次都带 --partial-dir，失败那次留下的半截文件下次能接着传。
+// 用户自己在 ExtraArgs 里写了 --partial* 就不动。
+func appendResumeArgs(args []string, p *RetryPolicy) []string {
+	if !p.enabled() {
+		return args
+	}
+	for _, a := range args {
+		if a == "--partial" || a == "-P" || strings.HasPrefix(a, "--partial-dir") {
+			return args
+		}
+	}
+	return append(args, "--partial-dir="+p.partialDir())
+}
+
+// serverResumeArgs：gokrazy 客户端不会把 --partial/--partial-dir 转给 server（rsyncopts/serveroptions.go 里注释掉了），
+// 本机 -> 远程时自己加到远端 rsync --server 的命令行上，半截文件由远端的 rsync receiver 保留。
+// 用户在 ExtraArgs 里写的 --partial* 优先；-P 只取 --partial 的含义
+func serverResumeArgs(serverArgs, extraArgs []string, p *RetryPolicy) []string {
+	if len(serverArgs) == 0 || serverArgs[0] != "--server" {
+		return serverArgs
+	}
+	resume := appendResumeArgs(nil, p)
+	for _, a := range extraArgs {
+		switch {
+		case a == "--partial" || a == "-P":
+			resume = []string{"--partial"}
+		case strings.HasPrefix(a, "--partial-dir"):
+			resume = []string{a}
+		}
+	}
+	if len(resume) == 0 {
+		return serverArgs
+	}
+	out := make([]string, 0, len(serverArgs)+1)
+	out = append(out, serverArgs[0])
+	out = append(out, resume...)
+	return append(out, serverArgs[1:]...)
+}
+
+// rsync 退出码里属于网络/连接问题的（见 rsync(1) EXIT VALUES）；255 是 ssh 自己连不上
+var transientRsyncExitCodes = map[int]bool{
+	10:  true, // error in socket I/O
+	12:  true, // error in rsync protocol data stream
+	30:  true, // timeout in data send/receive
+	35:  true, // timeout waiting for daemon connection
+	255: true, // ssh connection failed
+}
+
+// isTransientError：网络抖动类错误返回 true；远端命令正常跑完但返回失败、认证失败、配置错误等返回 false
+func isTransientError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	msg := err.Error()
+	// 认证失败/私钥问题：重试也没用
+	if strings.Contains(msg, "unable to authenticate") || strings.Contains(msg, "no supported methods remain") {
+		return false
+	}
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) && !errors.Is(err, syscall.EPIPE) {
+		return false
+	}
+
+	// 远端命令的退出码：rsync 明确表示网络问题的才重试
+	var sshExit *ssh.ExitError
+	if errors.As(err, &sshExit) {
+		return transientRsyncExitCodes[sshExit.ExitStatus()]
+	}
+	var execExit *exec.ExitError
+	if errors.As(err, &execExit) {
+		return transientRsyncExitCodes[execExit.ExitCode()]
+	}
+
+	// 连接被对端断开/session 没拿到退出状态
+	var missing *ssh.ExitMissingError
+	if errors.As(err, &missing) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, syscall.ETIMEDOUT) || errors.Is(err, syscall.EHOSTUNREACH) ||
+		errors.Is(err, syscall.ENETUNREACH) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	// 不少错误在 rsyncclient / scp 里被 %v 拼成了字符串，只能按文本兜底
+	for _, s := range []string{
+		"connection reset",
+		"connection refused",
+		"broken pipe",
+		"i/o timeout",
+		"unexpected EOF",
+		"use of closed network connection",
+		"no route to host",
+		"network is unreachable",
+	} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// sleepCtx：等 d 或 ctx 取消
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
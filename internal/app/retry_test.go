@@ -0,0 +1,70 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestIsTransientError(t *testing.T) {
+	// 真跑一个子进程拿 *exec.ExitError
+	exitErr := func(code int) error {
+		err := exec.Command("sh", "-c", fmt.Sprintf("exit %d", code)).Run()
+		if err == nil {
+			t.Fatalf("exit %d: no error", code)
+		}
+		return err
+	}
+
+	for _, tc := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"canceled", fmt.Errorf("run: %w", context.Canceled), false},
+		{"auth", errors.New("ssh: handshake failed: ssh: unable to authenticate, attempted methods [none publickey]"), false},
+		{"path", fmt.Errorf("open: %w", &os.PathError{Op: "open", Path: "/x", Err: syscall.ENOENT}), false},
+		{"plain", errors.New("something went wrong"), false},
+		{"eof", fmt.Errorf("read: %w", io.EOF), true},
+		{"unexpected eof", fmt.Errorf("read: %w", io.ErrUnexpectedEOF), true},
+		{"reset", fmt.Errorf("write: %w", syscall.ECONNRESET), true},
+		{"deadline", fmt.Errorf("dial: %w", context.DeadlineExceeded), true},
+		{"exit missing", &ssh.ExitMissingError{}, true},
+		{"reset text", errors.New("rsync: read error: connection reset by peer"), true},
+		{"rsync exit 12", fmt.Errorf("rsync: %w", exitErr(12)), true},
+		{"rsync exit 23", fmt.Errorf("rsync: %w", exitErr(23)), false},
+	} {
+		if got := isTransientError(tc.err); got != tc.want {
+			t.Errorf("%s: isTransientError(%v) = %v, want %v", tc.name, tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestServerResumeArgs(t *testing.T) {
+	server := []string{"--server", "--protocol=27", "-rltp", ".", "/dst"}
+	retry := &RetryPolicy{MaxAttempts: 3}
+	for _, tc := range []struct {
+		name  string
+		extra []string
+		p     *RetryPolicy
+		want  []string
+	}{
+		{"no retry", nil, &RetryPolicy{}, server},
+		{"default dir", nil, retry, []string{"--server", "--partial-dir=.rsync-partial", "--protocol=27", "-rltp", ".", "/dst"}},
+		{"user dir", []string{"--partial-dir=/tmp/p"}, retry, []string{"--server", "--partial-dir=/tmp/p", "--protocol=27", "-rltp", ".", "/dst"}},
+		{"user -P", []string{"-P"}, &RetryPolicy{}, []string{"--server", "--partial", "--protocol=27", "-rltp", ".", "/dst"}},
+	} {
+		if got := serverResumeArgs(server, tc.extra, tc.p); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,434 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	_ "time/tzdata" // Windows 上没有系统时区库，Timezone 也要能用
+
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// ===== 定时任务（schedules.yaml）：按 cron 表达式定时跑保存的 task =====
+//
+//	- name: "nightly-www"
+//	  task: "backup-www"
+//	  cron: "30 2 * * *"
+//	  timezone: "Asia/Shanghai"
+//	  enabled: true
+//	  skipIfRunning: true
+
+// Schedule：挂在某个保存的 task 上的 cron 定时
+type Schedule struct {
+	Name          string `json:"name" yaml:"name"`
+	Task          string `json:"task" yaml:"task"`
+	Cron          string `json:"cron" yaml:"cron"`         // 标准 5 字段，或 @daily / @every 1h 等
+	Timezone      string `json:"timezone" yaml:"timezone"` // IANA 时区名，空 = 本机时区
+	Enabled       bool   `json:"enabled" yaml:"enabled"`
+	SkipIfRunning bool   `json:"skipIfRunning" yaml:"skipIfRunning"` // 上一次还没跑完就跳过这次
+}
+
+// ScheduleStatus：Schedule 加上运行时状态（不落盘，重启后清空）
+type ScheduleStatus struct {
+	Schedule
+	NextRun   *time.Time `json:"nextRun,omitempty"`
+	LastRunAt *time.Time `json:"lastRunAt,omitempty"`
+	LastJobID string     `json:"lastJobId,omitempty"`
+	LastError string     `json:"lastError,omitempty"`
+}
+
+var (
+	ErrScheduleNotFound = errors.New("schedule not found")
+	ErrScheduleExists   = errors.New("schedule already exists")
+	ErrScheduleInvalid  = errors.New("invalid schedule")
+)
+
+const maxNextRuns = 100
+
+type scheduleEntry struct {
+	def   Schedule
+	sched cron.Schedule
+	loc   *time.Location
+	next  time.Time // 零值 = 不会再触发（停用）
+
+	lastRunAt time.Time
+	lastJobID string
+	lastError string
+}
+
+// Scheduler：维护 schedules.yaml，并在到点时通过 App.RunTask 创建任务
+type Scheduler struct {
+	app  *App
+	path string
+
+	mu      sync.Mutex
+	entries map[string]*scheduleEntry
+	firing  map[string]bool // 正在 fire 的定时（RunTask 预检查可能要好几秒），没返回前不重复触发
+	wake    chan struct{}
+}
+
+// parseCron 解析 cron 表达式和时区
+func parseCron(expr, tz string) (cron.Schedule, *time.Location, error) {
+	loc := time.Local
+	if tz != "" {
+		l, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: timezone %q: %v", ErrScheduleInvalid, tz, err)
+		}
+		loc = l
+	}
+	sched, err := cron.ParseStandard(expr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: cron %q: %v", ErrScheduleInvalid, expr, err)
+	}
+	return sched, loc, nil
+}
+
+// NextRuns 预览 from 之后的 n 次触发时间（按 tz 显示）
+func NextRuns(expr, tz string, from time.Time, n int) ([]time.Time, error) {
+	sched, loc, err := parseCron(expr, tz)
+	if err != nil {
+		return nil, err
+	}
+	n = min(max(n, 1), maxNextRuns)
+	out := make([]time.Time, 0, n)
+	t := from.In(loc)
+	for range n {
+		t = sched.Next(t)
+		if t.IsZero() {
+			break
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+func newScheduleEntry(def Schedule, now time.Time) (*scheduleEntry, error) {
+	if def.Name == "" {
+		return nil, fmt.Errorf("%w: name is empty", ErrScheduleInvalid)
+	}
+	if strings.ContainsAny(def.Name, "/?#") {
+		return nil, fmt.Errorf("%w: name %q must not contain '/', '?' or '#'", ErrScheduleInvalid, def.Name)
+	}
+	if def.Task == "" {
+		return nil, fmt.Errorf("%w: task is empty", ErrScheduleInvalid)
+	}
+	sched, loc, err := parseCron(def.Cron, def.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	e := &scheduleEntry{def: def, sched: sched, loc: loc}
+	e.reschedule(now)
+	return e, nil
+}
+
+func (e *scheduleEntry) reschedule(now time.Time) {
+	e.next = time.Time{}
+	if e.def.Enabled {
+		e.next = e.sched.Next(now.In(e.loc))
+	}
+}
+
+func (e *scheduleEntry) status() ScheduleStatus {
+	st := ScheduleStatus{
+		Schedule:  e.def,
+		LastJobID: e.lastJobID,
+		LastError: e.lastError,
+	}
+	if !e.next.IsZero() {
+		next := e.next
+		st.NextRun = &next
+	}
+	if !e.lastRunAt.IsZero() {
+		last := e.lastRunAt
+		st.LastRunAt = &last
+	}
+	return st
+}
+
+// LoadSchedules 读取 schedules.yaml；文件不存在视为空。需要调用 Run 才会开始触发。
+func LoadSchedules(a *App, path string) (*Scheduler, error) {
+	s := &Scheduler{
+		app:     a,
+		path:    path,
+		entries: make(map[string]*scheduleEntry),
+		firing:  make(map[string]bool),
+		wake:    make(chan struct{}, 1),
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read schedules yaml: %w", err)
+	}
+	var list []Schedule
+	if err := yaml.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("unmarshal schedules yaml: %w", err)
+	}
+	now := time.Now()
+	for _, def := range list {
+		if _, dup := s.entries[def.Name]; dup {
+			return nil, fmt.Errorf("schedules yaml: duplicate schedule %q", def.Name)
+		}
+		e, err := newScheduleEntry(def, now)
+		if err != nil {
+			return nil, fmt.Errorf("schedules yaml: %s: %w", def.Name, err)
+		}
+		s.entries[def.Name] = e
+	}
+	return s, nil
+}
+
+func (s *Scheduler) List() []ScheduleStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]ScheduleStatus, 0, len(s.entries))
+	for _, e := range s.entries {
+		out = append(out, e.status())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func (s *Scheduler) Get(name string) (ScheduleStatus, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[name]
+	if !ok {
+		return ScheduleStatus{}, false
+	}
+	return e.status(), true
+}
+
+// Create 新增定时；引用的 task 必须存在
+func (s *Scheduler) Create(def Schedule) (ScheduleStatus, error) {
+	if err := s.checkTask(def); err != nil {
+		return ScheduleStatus{}, err
+	}
+	e, err := newScheduleEntry(def, time.Now())
+	if err != nil {
+		return ScheduleStatus{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[def.Name]; ok {
+		return ScheduleStatus{}, ErrScheduleExists
+	}
+	s.entries[def.Name] = e
+	if err := s.saveLocked(); err != nil {
+		delete(s.entries, def.Name)
+		return ScheduleStatus{}, err
+	}
+	s.poke()
+	return e.status(), nil
+}
+
+// Update 覆盖已有定时（def.Name 与 name 不同表示改名），运行时状态保留
+func (s *Scheduler) Update(name string, def Schedule) (ScheduleStatus, error) {
+	if err := s.checkTask(def); err != nil {
+		return ScheduleStatus{}, err
+	}
+	e, err := newScheduleEntry(def, time.Now())
+	if err != nil {
+		return ScheduleStatus{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.entries[name]
+	if !ok {
+		return ScheduleStatus{}, ErrScheduleNotFound
+	}
+	if def.Name != name {
+		if _, exists := s.entries[def.Name]; exists {
+			return ScheduleStatus{}, ErrScheduleExists
+		}
+	}
+	e.lastRunAt, e.lastJobID, e.lastError = old.lastRunAt, old.lastJobID, old.lastError
+	delete(s.entries, name)
+	s.entries[def.Name] = e
+	if err := s.saveLocked(); err != nil {
+		delete(s.entries, def.Name)
+		s.entries[name] = old
+		return ScheduleStatus{}, err
+	}
+	s.poke()
+	return e.status(), nil
+}
+
+func (s *Scheduler) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.entries[name]
+	if !ok {
+		return ErrScheduleNotFound
+	}
+	delete(s.entries, name)
+	if err := s.saveLocked(); err != nil {
+		s.entries[name] = old
+		return err
+	}
+	s.poke()
+	return nil
+}
+
+// TaskUsers：引用了 task 的定时名（删除/改名 task 前检查）
+func (s *Scheduler) TaskUsers(task string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var names []string
+	for name, e := range s.entries {
+		if e.def.Task == task {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *Scheduler) checkTask(def Schedule) error {
+	if def.Task == "" {
+		return fmt.Errorf("%w: task is empty", ErrScheduleInvalid)
+	}
+	if _, ok := s.app.Tasks.Get(def.Task); !ok {
+		return fmt.Errorf("%w: task %q not found", ErrScheduleInvalid, def.Task)
+	}
+	return nil
+}
+
+func (s *Scheduler) saveLocked() error {
+	list := make([]Schedule, 0, len(s.entries))
+	for _, e := range s.entries {
+		list = append(list, e.def)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	if err := writeYAMLFile(s.path, list); err != nil {
+		return fmt.Errorf("save schedules yaml: %w", err)
+	}
+	return nil
+}
+
+// poke 让 Run 重新计算下一次唤醒时间
+func (s *Scheduler) poke() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run 阻塞运行调度循环，直到 ctx 取消
+func (s *Scheduler) Run(ctx context.Context) {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		now := time.Now()
+		var due []string
+		var wait time.Duration = -1
+
+		s.mu.Lock()
+		for name, e := range s.entries {
+			if e.next.IsZero() {
+				continue
+			}
+			if !e.next.After(now) {
+				if s.firing[name] {
+					log.Printf("[schedule] %s: previous trigger still submitting, skipped", name)
+					e.lastRunAt, e.lastError = now, "skipped: previous trigger still submitting"
+				} else {
+					s.firing[name] = true
+					due = append(due, name)
+				}
+				e.reschedule(now)
+			}
+			if !e.next.IsZero() {
+				if d := e.next.Sub(now); wait < 0 || d < wait {
+					wait = d
+				}
+			}
+		}
+		s.mu.Unlock()
+
+		// 每个定时单独一个 goroutine：预检查/SSH 慢的定时不拖住其他定时的触发
+		for _, name := range due {
+			go func() {
+				defer func() {
+					s.mu.Lock()
+					delete(s.firing, name)
+					s.mu.Unlock()
+				}()
+				s.fire(name, now)
+			}()
+		}
+
+		if wait < 0 {
+			wait = time.Hour // 没有启用的定时：等 poke
+		}
+		timer.Reset(wait) // go1.23+ 的 Reset 不会收到旧的过期值，不用先 drain
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+// fire 触发一次：SkipIfRunning 时先看上一次的任务有没有结束
+func (s *Scheduler) fire(name string, at time.Time) {
+	s.mu.Lock()
+	e, ok := s.entries[name]
+	if !ok {
+		s.mu.Unlock()
+		return
+	}
+	def := e.def
+	lastJobID := e.lastJobID
+	s.mu.Unlock()
+
+	if def.SkipIfRunning && lastJobID != "" {
+		if prev, ok := s.app.JobManager.GetJob(lastJobID); ok && !prev.CurrentStatus().IsFinished() {
+			log.Printf("[schedule] %s: previous job %s still running, skipped", name, lastJobID)
+			s.record(name, at, "", "skipped: previous job "+lastJobID+" still running")
+			return
+		}
+	}
+
+	sub, err := s.app.RunTask(def.Task, SubmitOptions{Schedule: name})
+	switch {
+	case err != nil:
+		msg := err.Error()
+		if errors.Is(err, ErrPrecheckFailed) && sub != nil && sub.Precheck != nil {
+			msg += ": " + sub.Precheck.Message
+		}
+		log.Printf("[schedule] %s: run task %s: %s", name, def.Task, msg)
+		s.record(name, at, "", msg)
+	default:
+		log.Printf("[schedule] %s: started job %s", name, sub.Job.ID)
+		s.record(name, at, sub.Job.ID, "")
+	}
+}
+
+func (s *Scheduler) record(name string, at time.Time, jobID, errMsg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[name]
+	if !ok {
+		return
+	}
+	e.lastRunAt = at
+	e.lastError = errMsg
+	if jobID != "" {
+		e.lastJobID = jobID
+	}
+}
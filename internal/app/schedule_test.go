@@ -0,0 +1,83 @@
+package app
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNextRuns(t *testing.T) {
+	from := time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC)
+
+	got, err := NextRuns("30 2 * * *", "Asia/Shanghai", from, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"2024-03-10T02:30:00+08:00",
+		"2024-03-11T02:30:00+08:00",
+		"2024-03-12T02:30:00+08:00",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d runs, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if s := got[i].Format(time.RFC3339); s != want[i] {
+			t.Errorf("run %d = %s, want %s", i, s, want[i])
+		}
+	}
+
+	// 夏令时切换当天（America/New_York 2024-03-10 02:00 → 03:00）：按当地时间 09:00 触发
+	got, err = NextRuns("0 9 * * *", "America/New_York", from, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := got[1].Format(time.RFC3339); s != "2024-03-10T09:00:00-04:00" {
+		t.Errorf("DST run = %s", s)
+	}
+
+	for _, c := range []struct{ expr, tz string }{
+		{"61 * * * *", ""},
+		{"* * * * *", "Mars/Olympus"},
+	} {
+		if _, err := NextRuns(c.expr, c.tz, from, 1); !errors.Is(err, ErrScheduleInvalid) {
+			t.Errorf("NextRuns(%q, %q) err = %v, want ErrScheduleInvalid", c.expr, c.tz, err)
+		}
+	}
+}
+
+func TestDeleteTaskUsedBySchedule(t *testing.T) {
+	dir := t.TempDir()
+	tasks, err := LoadTasks(filepath.Join(dir, "tasks.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &App{Tasks: tasks}
+	if a.Schedules, err = LoadSchedules(a, filepath.Join(dir, "schedules.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tasks.Create(TaskDef{Name: "www"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Schedules.Create(Schedule{Name: "nightly", Task: "www", Cron: "@daily"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.DeleteTask("www"); !errors.Is(err, ErrTaskInUse) {
+		t.Fatalf("delete err = %v, want ErrTaskInUse", err)
+	}
+	if err := a.UpdateTask("www", TaskDef{Name: "www2"}); !errors.Is(err, ErrTaskInUse) {
+		t.Fatalf("rename err = %v, want ErrTaskInUse", err)
+	}
+	if err := a.UpdateTask("www", TaskDef{Name: "www", Description: "same name"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Schedules.Delete("nightly"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.DeleteTask("www"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,99 @@
+package app
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ===== 文件选择：一个任务传源目录下选中的若干文件/目录 =====
+//
+// Endpoint.Files 非空时，Path 是基准目录，Files 是相对它的路径（文件浏览器里勾选的那几项）。
+// 每一项都按名字放进目标目录，和 `rsync base/a base/sub/b dest/` 一样（不保留中间目录），
+// 所以选中项的名字不能重复。
+//   - Go-native（本机参与的传输）：把选中项作为多个源交给 rsyncclient.Run / 远程 sender
+//   - 命令行 rsync（OneHop / via_host）：用 --files-from + --no-relative 传同样的列表
+
+// validateSelection：只有源端能带 Files；每项必须是基准目录下的相对路径
+func validateSelection(source, dest Endpoint) error {
+	if len(dest.Files) > 0 {
+		return fmt.Errorf("dest endpoint cannot have a file selection")
+	}
+	seen := make(map[string]string, len(source.Files))
+	for _, f := range source.Files {
+		clean := path.Clean(filepath.ToSlash(f))
+		if f == "" || clean == "." || path.IsAbs(clean) || filepath.IsAbs(f) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return fmt.Errorf("invalid selected path %q: must be relative to %s", f, source.Path)
+		}
+		name := path.Base(clean)
+		if prev, ok := seen[name]; ok {
+			return fmt.Errorf("selected paths %q and %q would both be copied as %q", prev, f, name)
+		}
+		seen[name] = f
+	}
+	return nil
+}
+
+// sourcePaths：实际要传的源路径；没有选择时就是 Path 本身
+func (e Endpoint) sourcePaths() []string {
+	if len(e.Files) == 0 {
+		return []string{e.Path}
+	}
+	join := path.Join
+	if e.HostName == "local" {
+		join = filepath.Join
+	}
+	out := make([]string, 0, len(e.Files))
+	for _, f := range e.Files {
+		out = append(out, join(e.Path, filepath.FromSlash(path.Clean(filepath.ToSlash(f)))))
+	}
+	return out
+}
+
+// localSelectionTotals：本机源（可能是多个选中项）的总字节数/文件数
+func localSelectionTotals(e Endpoint) (bytesTotal, filesTotal int64) {
+	for _, p := range e.sourcePaths() {
+		b, f := localPathTotals(p)
+		bytesTotal += b
+		filesTotal += f
+	}
+	return bytesTotal, filesTotal
+}
+
+// filesFromArgs：命令行 rsync 传选择时追加的参数。列表用 bash 的进程替换现场生成（\0 分隔），
+// 执行端不用先写临时文件。
+// --files-from 会关掉 -a 隐含的 -r，这里补上；--no-relative 让每一项按名字落到目标目录，和 Go-native 一致。
+func filesFromArgs(opts *RsyncOptions, files []string) (args []string, filesFrom string) {
+	if opts.Archive {
+		args = append(args, "-r")
+	}
+	args = append(args, "--no-relative", "--from0")
+
+	quoted := make([]string, 0, len(files))
+	for _, f := range files {
+		quoted = append(quoted, shQuote(path.Clean(filepath.ToSlash(f))))
+	}
+	return args, `--files-from=<(printf '%s\0' ` + strings.Join(quoted, " ") + ")"
+}
+
+// selectionCLI：命令行 rsync 的源路径和选择相关参数；有选择时源路径是基准目录（结尾带 /）
+func selectionCLI(opts *RsyncOptions, src Endpoint) (srcPath string, args []string, filesFrom string) {
+	if len(src.Files) == 0 {
+		return src.Path, nil, ""
+	}
+	args, filesFrom = filesFromArgs(opts, src.Files)
+	if pathHasTrailingSeparator(src.Path) {
+		return src.Path, args, filesFrom
+	}
+	return src.Path + "/", args, filesFrom
+}
+
+// rsyncCommandLine：拼 shell 命令行；filesFrom 是进程替换，不能再加引号
+func rsyncCommandLine(args []string, filesFrom, srcSpec, dstSpec string) string {
+	cmd := "rsync " + joinShellArgs(args)
+	if filesFrom != "" {
+		cmd += " " + filesFrom
+	}
+	return cmd + " " + shQuote(srcSpec) + " " + shQuote(dstSpec)
+}
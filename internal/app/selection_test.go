@@ -0,0 +1,39 @@
+package app
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSelectionCLI(t *testing.T) {
+	opts := &RsyncOptions{Archive: true}
+	src := Endpoint{HostName: "h", Path: "/base", Files: []string{"a", "sub/b"}}
+
+	srcPath, args, filesFrom := selectionCLI(opts, src)
+	if srcPath != "/base/" {
+		t.Fatalf("srcPath = %q", srcPath)
+	}
+	if want := []string{"-r", "--no-relative", "--from0"}; !reflect.DeepEqual(args, want) {
+		t.Fatalf("args = %q, want %q", args, want)
+	}
+	if want := `--files-from=<(printf '%s\0' 'a' 'sub/b')`; filesFrom != want {
+		t.Fatalf("filesFrom = %s, want %s", filesFrom, want)
+	}
+
+	// 进程替换原样拼进去，源和目标照常加引号
+	cmd := rsyncCommandLine(append([]string{"-a"}, args...), filesFrom, "h:"+srcPath, "/dst dir/")
+	want := `rsync '-a' '-r' '--no-relative' '--from0' ` + filesFrom + ` 'h:/base/' '/dst dir/'`
+	if cmd != want {
+		t.Fatalf("command line:\n got %s\nwant %s", cmd, want)
+	}
+
+	// 没有选择：源路径原样，不加参数
+	src.Files = nil
+	if p, args, ff := selectionCLI(opts, src); p != "/base" || args != nil || ff != "" {
+		t.Fatalf("no selection = %q %q %q", p, args, ff)
+	}
+	if cmd := rsyncCommandLine([]string{"-a"}, "", "/base", "/dst"); strings.Contains(cmd, "files-from") {
+		t.Fatalf("command line = %s", cmd)
+	}
+}
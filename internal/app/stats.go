@@ -0,0 +1,149 @@
+package app
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gokrazy/rsync/rsyncclient"
+)
+
+// TransferStats：一次传输结束时的统计，用来审计每次同步实际搬了多少数据。
+// 不同执行方式能拿到的字段不一样，拿不到的保持 0（Source 说明来源）。
+type TransferStats struct {
+	Source              string  `json:"source"` // rsync-cli / go-rsync / scp / tar / relay-stream
+	FilesTotal          int64   `json:"filesTotal"`
+	FilesTransferred    int64   `json:"filesTransferred"`
+	FilesCreated        int64   `json:"filesCreated"`
+	FilesDeleted        int64   `json:"filesDeleted"`
+	TotalFileSize       int64   `json:"totalFileSize"`
+	TransferredFileSize int64   `json:"transferredFileSize"`
+	LiteralData         int64   `json:"literalData"` // 实际发送的文件数据
+	MatchedData         int64   `json:"matchedData"` // 靠增量匹配省下的数据
+	BytesSent           int64   `json:"bytesSent"`
+	BytesReceived       int64   `json:"bytesReceived"`
+	Speedup             float64 `json:"speedup"`
+}
+
+// setStats：记录（或清空）本次尝试的统计
+func (j *Job) setStats(s *TransferStats) {
+	j.mu.Lock()
+	j.Stats = s
+	j.notifyLocked()
+	j.mu.Unlock()
+}
+
+// computeSpeedup：rsync 的定义，总大小 / 线上字节数
+func (s *TransferStats) computeSpeedup() {
+	if wire := s.BytesSent + s.BytesReceived; wire > 0 {
+		s.Speedup = float64(s.TotalFileSize) / float64(wire)
+	}
+}
+
+// ===== 解析命令行 rsync 的 --stats 输出 =====
+
+var (
+	statsLineRe    = regexp.MustCompile(`^(Number of files|Number of created files|Number of deleted files|Number of regular files transferred|Number of files transferred|Total file size|Total transferred file size|Literal data|Matched data|Total bytes sent|Total bytes received):\s*([\d,.]+[KMGT]?)`)
+	statsSpeedupRe = regexp.MustCompile(`^total size is ([\d,.]+[KMGT]?)\s+speedup is ([\d,.]+)`)
+)
+
+// parseStatsLine 解析一行 --stats 输出；不是统计行返回 false
+func parseStatsLine(line string, s *TransferStats) bool {
+	line = strings.TrimSpace(line)
+	if m := statsSpeedupRe.FindStringSubmatch(line); m != nil {
+		s.TotalFileSize = parseStatsNumber(m[1])
+		s.Speedup, _ = strconv.ParseFloat(strings.ReplaceAll(m[2], ",", ""), 64)
+		return true
+	}
+	m := statsLineRe.FindStringSubmatch(line)
+	if m == nil {
+		return false
+	}
+	n := parseStatsNumber(m[2])
+	switch m[1] {
+	case "Number of files":
+		s.FilesTotal = n
+	case "Number of created files":
+		s.FilesCreated = n
+	case "Number of deleted files":
+		s.FilesDeleted = n
+	case "Number of regular files transferred", "Number of files transferred":
+		s.FilesTransferred = n
+	case "Total file size":
+		s.TotalFileSize = n
+	case "Total transferred file size":
+		s.TransferredFileSize = n
+	case "Literal data":
+		s.LiteralData = n
+	case "Matched data":
+		s.MatchedData = n
+	case "Total bytes sent":
+		s.BytesSent = n
+	case "Total bytes received":
+		s.BytesReceived = n
+	}
+	return true
+}
+
+// parseStatsNumber：1,234,567 或 -h 下的 1.23M（rsync 的 -h 按 1000 进位）
+func parseStatsNumber(s string) int64 {
+	mult := 1.0
+	switch s[len(s)-1] {
+	case 'K':
+		mult = 1e3
+	case 'M':
+		mult = 1e6
+	case 'G':
+		mult = 1e9
+	case 'T':
+		mult = 1e12
+	}
+	if mult > 1 {
+		s = s[:len(s)-1]
+	}
+	s = strings.ReplaceAll(s, ",", "")
+	if mult == 1 && !strings.Contains(s, ".") {
+		n, _ := strconv.ParseInt(s, 10, 64)
+		return n
+	}
+	f, _ := strconv.ParseFloat(s, 64)
+	return int64(f * mult)
+}
+
+// goRsyncStats：把 rsyncclient 的统计换算成 TransferStats（收发字节按本机这一端算，和命令行 rsync 一致）。
+// rsyncclient 只给出线上读写字节和总大小，文件数用进度里扫描到的总数。
+func goRsyncStats(job *Job, res *rsyncclient.Result) *TransferStats {
+	s := &TransferStats{Source: "go-rsync"}
+	if res != nil && res.Stats != nil {
+		s.TotalFileSize = res.Stats.Size
+		s.BytesSent = res.Stats.Written
+		s.BytesReceived = res.Stats.Read
+	}
+	job.mu.Lock()
+	s.FilesTotal = job.Progress.FilesTotal
+	job.mu.Unlock()
+	s.computeSpeedup()
+	return s
+}
+
+// progressStats：scp/tar 兜底没有 rsync 统计，用计数器进度凑一份（全量发送，没有增量匹配）
+func progressStats(job *Job, source string) *TransferStats {
+	job.mu.Lock()
+	p := job.Progress
+	job.mu.Unlock()
+
+	s := &TransferStats{
+		Source:              source,
+		FilesTotal:          p.FilesTotal,
+		FilesTransferred:    p.FilesDone,
+		TotalFileSize:       p.BytesTotal,
+		TransferredFileSize: p.BytesDone,
+		LiteralData:         p.BytesDone,
+	}
+	if source == "scp" {
+		// scp 按原样发文件内容；tar.gz 压缩后的线上字节数拿不到
+		s.BytesSent = p.BytesDone
+		s.computeSpeedup()
+	}
+	return s
+}
@@ -0,0 +1,83 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ===== 提交传输：Plan → 预检查 → 创建并启动 Job =====
+// /api/transfers、重跑/克隆等入口共用
+
+// ErrPrecheckFailed：预检查没通过，没有创建任务（Submission.Precheck 里有原因）
+var ErrPrecheckFailed = errors.New("precheck failed")
+
+// SubmitError：标明失败发生在哪一步，方便 HTTP 层选状态码
+type SubmitError struct {
+	Stage string // "plan" / "precheck"
+	Err   error
+}
+
+func (e *SubmitError) Error() string { return e.Stage + " error: " + e.Err.Error() }
+func (e *SubmitError) Unwrap() error { return e.Err }
+
+// Submission：一次提交的结果；预检查失败时 Job 为 nil
+type Submission struct {
+	Job      *Job
+	Plan     *TransferPlan
+	Precheck *PrecheckResult
+}
+
+// SubmitOptions：记录任务是从哪里来的
+type SubmitOptions struct {
+	ParentID string // 重跑/克隆自哪个任务
+	Task     string // 由哪个保存的 task 启动
+	Schedule string // 由哪个定时触发
+	Pipeline string // 属于哪次流水线运行
+	FanOut   string // 属于哪个扇出父任务
+}
+
+// SubmitTransfer 生成执行计划、做预检查，通过后创建 Job 并排队启动
+func (a *App) SubmitTransfer(req TransferRequest, opts SubmitOptions) (*Submission, error) {
+	plan, err := a.PlanTransfer(req)
+	if err != nil {
+		return nil, &SubmitError{Stage: "plan", Err: err}
+	}
+	if plan.Mode == ExecFanOut {
+		// 每个目标在子任务里各自预检查
+		sub := &Submission{Plan: plan, Job: a.JobManager.newJob(req, plan, opts)}
+		a.startFanOut(sub.Job)
+		return sub, nil
+	}
+	precheck, err := a.RunPrechecks(plan)
+	if err != nil {
+		return nil, &SubmitError{Stage: "precheck", Err: err}
+	}
+	sub := &Submission{Plan: plan, Precheck: precheck}
+	if !precheck.SourceReadable || !precheck.DestWritable {
+		return sub, ErrPrecheckFailed
+	}
+
+	sub.Job = a.JobManager.newJob(req, plan, opts)
+	a.JobManager.StartJob(sub.Job)
+	return sub, nil
+}
+
+// CloneRequest 返回 job 原始请求的深拷贝，patch（JSON，可为空）里出现的字段覆盖原值
+func (j *Job) CloneRequest(patch []byte) (TransferRequest, error) {
+	var req TransferRequest
+	b, err := json.Marshal(j.Request)
+	if err != nil {
+		return req, fmt.Errorf("copy request: %w", err)
+	}
+	if err := json.Unmarshal(b, &req); err != nil {
+		return req, fmt.Errorf("copy request: %w", err)
+	}
+	if len(bytes.TrimSpace(patch)) > 0 {
+		if err := json.Unmarshal(patch, &req); err != nil {
+			return req, fmt.Errorf("invalid override: %w", err)
+		}
+	}
+	return req, nil
+}
@@ -0,0 +1,208 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ===== 保存的传输任务（tasks.yaml）=====
+//
+//	- name: "backup-www"
+//	  description: "node-01 网站目录备份到 node-02"
+//	  endpointA: { hostName: "node-01", path: "/var/www/" }
+//	  endpointB: { hostName: "node-02", path: "/backup/www/" }
+//	  direction: "A_to_B"
+//	  execSide: "auto"
+//	  options: { archive: true, compress: true }
+
+// TaskDef：一个命名的、可重复执行的 TransferRequest
+type TaskDef struct {
+	Name            string        `json:"name" yaml:"name"`
+	Description     string        `json:"description" yaml:"description"`
+	Notify          *NotifyConfig `json:"notify,omitempty" yaml:"notify,omitempty"` // 不填则用全局 notify.yaml
+	TransferRequest `yaml:",inline"`
+}
+
+var (
+	ErrTaskNotFound = errors.New("task not found")
+	ErrTaskExists   = errors.New("task already exists")
+	ErrTaskName     = errors.New("invalid task name")
+	ErrTaskInUse    = errors.New("task is used by schedules")
+)
+
+// TaskStore：tasks.yaml 的内存副本，每次修改整体写回文件
+type TaskStore struct {
+	mu    sync.RWMutex
+	path  string
+	tasks map[string]TaskDef
+}
+
+// LoadTasks 读取 tasks.yaml；文件不存在视为空
+func LoadTasks(path string) (*TaskStore, error) {
+	s := &TaskStore{path: path, tasks: make(map[string]TaskDef)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read tasks yaml: %w", err)
+	}
+	var list []TaskDef
+	if err := yaml.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("unmarshal tasks yaml: %w", err)
+	}
+	for _, t := range list {
+		if err := validateTaskName(t.Name); err != nil {
+			return nil, fmt.Errorf("tasks yaml: %w", err)
+		}
+		if _, dup := s.tasks[t.Name]; dup {
+			return nil, fmt.Errorf("tasks yaml: duplicate task %q", t.Name)
+		}
+		s.tasks[t.Name] = t
+	}
+	return s, nil
+}
+
+func validateTaskName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("%w: empty", ErrTaskName)
+	}
+	if strings.ContainsAny(name, "/?#") {
+		return fmt.Errorf("%w: %q must not contain '/', '?' or '#'", ErrTaskName, name)
+	}
+	return nil
+}
+
+// List 按名字排序返回全部 task
+func (s *TaskStore) List() []TaskDef {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]TaskDef, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func (s *TaskStore) Get(name string) (TaskDef, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t, ok := s.tasks[name]
+	return t, ok
+}
+
+// Create 新增 task；同名已存在返回 ErrTaskExists
+func (s *TaskStore) Create(t TaskDef) error {
+	if err := validateTaskName(t.Name); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tasks[t.Name]; ok {
+		return ErrTaskExists
+	}
+	s.tasks[t.Name] = t
+	if err := s.saveLocked(); err != nil {
+		delete(s.tasks, t.Name)
+		return err
+	}
+	return nil
+}
+
+// Update 覆盖已有 task；t.Name 与 name 不同表示改名
+func (s *TaskStore) Update(name string, t TaskDef) error {
+	if err := validateTaskName(t.Name); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.tasks[name]
+	if !ok {
+		return ErrTaskNotFound
+	}
+	if t.Name != name {
+		if _, exists := s.tasks[t.Name]; exists {
+			return ErrTaskExists
+		}
+		delete(s.tasks, name)
+	}
+	s.tasks[t.Name] = t
+	if err := s.saveLocked(); err != nil {
+		delete(s.tasks, t.Name)
+		s.tasks[name] = old
+		return err
+	}
+	return nil
+}
+
+func (s *TaskStore) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.tasks[name]
+	if !ok {
+		return ErrTaskNotFound
+	}
+	delete(s.tasks, name)
+	if err := s.saveLocked(); err != nil {
+		s.tasks[name] = old
+		return err
+	}
+	return nil
+}
+
+func (s *TaskStore) saveLocked() error {
+	list := make([]TaskDef, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	if err := writeYAMLFile(s.path, list); err != nil {
+		return fmt.Errorf("save tasks yaml: %w", err)
+	}
+	return nil
+}
+
+// UpdateTask：Tasks.Update，但被定时引用的 task 不能改名（否则定时指向一个不存在的 task）
+func (a *App) UpdateTask(name string, t TaskDef) error {
+	if t.Name != name {
+		if err := a.checkTaskUnused(name); err != nil {
+			return err
+		}
+	}
+	return a.Tasks.Update(name, t)
+}
+
+// DeleteTask：Tasks.Delete，但被定时引用的 task 要先删掉/改掉那些定时
+func (a *App) DeleteTask(name string) error {
+	if err := a.checkTaskUnused(name); err != nil {
+		return err
+	}
+	return a.Tasks.Delete(name)
+}
+
+func (a *App) checkTaskUnused(name string) error {
+	if a.Schedules == nil {
+		return nil
+	}
+	if users := a.Schedules.TaskUsers(name); len(users) > 0 {
+		return fmt.Errorf("%w: %s", ErrTaskInUse, strings.Join(users, ", "))
+	}
+	return nil
+}
+
+// RunTask 用保存的 task 创建并启动一个 Job（opts.Task 会被设为 name）
+func (a *App) RunTask(name string, opts SubmitOptions) (*Submission, error) {
+	t, ok := a.Tasks.Get(name)
+	if !ok {
+		return nil, ErrTaskNotFound
+	}
+	opts.Task = t.Name
+	return a.SubmitTransfer(t.TransferRequest, opts)
+}
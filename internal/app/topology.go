@@ -0,0 +1,245 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ===== execSide=auto：探测拓扑后选执行端 =====
+//
+// 两端都是远程时，分别 ssh 到源机和目标机：
+//   - 有没有 rsync
+//   - 能不能直连另一端的 SSH 端口（在候选执行端上做 TCP connect），以及连接耗时
+//   - 本机到这台主机的往返耗时
+// 能直连的一端里选连接更快的那个；两端都连不通对方时经本机中转。
+// 探测结果按 (源, 目标, LAN) 缓存一会儿，预览/提交不会每次都重新探测；保存任务不探测。
+// 缓存键带上主机的连接配置，hosts.yaml 改了地址/端口/用户后不会用到旧结果。
+
+const (
+	topologyCacheTTL   = 5 * time.Minute
+	topologyTCPTimeout = 5 // 秒
+)
+
+// ReachProbe：在一台主机上 TCP connect 另一台主机的 SSH 端口
+type ReachProbe struct {
+	Target    string  `json:"target"` // host:port
+	OK        bool    `json:"ok"`
+	LatencyMs float64 `json:"latencyMs,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// HostProbe：一台候选执行端的探测结果
+type HostProbe struct {
+	Host      string     `json:"host"`
+	Rsync     bool       `json:"rsync"`
+	RTTMs     float64    `json:"rttMs"` // 本机 -> 该主机执行一条命令的往返耗时
+	Peer      ReachProbe `json:"peer"`  // 该主机 -> 另一端
+	Error     string     `json:"error,omitempty"`
+	reachable bool
+}
+
+// TopologyProbe：auto 模式选执行端时的依据，记在 plan 里
+type TopologyProbe struct {
+	Source   HostProbe `json:"source"`
+	Dest     HostProbe `json:"dest"`
+	ProbedAt time.Time `json:"probedAt"`
+}
+
+type topologyCache struct {
+	mu      sync.Mutex
+	entries map[string]*TopologyProbe
+}
+
+func (c *topologyCache) get(key string, now time.Time) *TopologyProbe {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := c.entries[key]
+	if t == nil || now.Sub(t.ProbedAt) > topologyCacheTTL {
+		return nil
+	}
+	return t
+}
+
+func (c *topologyCache) put(key string, t *TopologyProbe) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]*TopologyProbe)
+	}
+	c.entries[key] = t
+}
+
+// probeTopology：并发探测两端；结果会缓存 topologyCacheTTL。
+// 每端要连的对方地址先按 LAN 探测决定（见 lan.go）
+func (a *App) probeTopology(ctx context.Context, src, dst *Host, useLan bool) *TopologyProbe {
+	key := fmt.Sprintf("%s\xff%s\xff%v", hostCacheKey(src), hostCacheKey(dst), useLan)
+	if t := a.topology.get(key, time.Now()); t != nil {
+		return t
+	}
+
+	t := &TopologyProbe{}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		t.Source = probeExecCandidate(ctx, src, a.decideHop(ctx, src, dst, useLan).target())
+	}()
+	go func() {
+		defer wg.Done()
+		t.Dest = probeExecCandidate(ctx, dst, a.decideHop(ctx, dst, src, useLan).target())
+	}()
+	wg.Wait()
+	t.ProbedAt = time.Now()
+
+	// 连不上的主机、超时的探测不缓存，下次提交再试
+	if t.Source.reachable && t.Dest.reachable && ctx.Err() == nil {
+		a.topology.put(key, t)
+	}
+	return t
+}
+
+// probeExecCandidate：在 h 上查 rsync、连 peer；再跑一条空命令量本机到 h 的往返
+func probeExecCandidate(ctx context.Context, h *Host, peer DialTarget) HostProbe {
+	p := HostProbe{Host: h.Config.Name}
+	out, err := runSSHCtx(ctx, h, topologyProbeScript(peer))
+	if err != nil && !strings.Contains(out, "RSYNC=") {
+		p.Error = err.Error()
+		return p
+	}
+	p.reachable = true
+	p.Rsync, p.Peer = parseTopologyProbe(out)
+	p.Peer.Target = fmt.Sprintf("%s:%d", peer.Host, peer.Port)
+
+	// 上面那次已经建好连接（会复用），这次只算命令往返
+	start := time.Now()
+	if _, err := runSSHCtx(ctx, h, "true"); err == nil {
+		p.RTTMs = msSince(start)
+	}
+	return p
+}
+
+// topologyProbeScript：输出 RSYNC=0/1 和 REACH=ok <微秒> / REACH=fail <原因>
+func topologyProbeScript(peer DialTarget) string {
+	return "if command -v rsync >/dev/null 2>&1; then echo RSYNC=1; else echo RSYNC=0; fi\n" +
+		reachProbeScript(peer, topologyTCPTimeout)
+}
+
+// reachProbeScript：在远程 TCP connect peer，输出 REACH=ok <微秒> / REACH=fail <原因>。
+// 优先用 nc；没有 nc 时用 bash 的 /dev/tcp。
+func reachProbeScript(peer DialTarget, timeoutSec int) string {
+	host, port := shQuote(peer.Host), strconv.Itoa(peer.Port)
+	t := strconv.Itoa(timeoutSec)
+	return `now() { date +%s%N 2>/dev/null; }
+start=$(now)
+if command -v nc >/dev/null 2>&1; then
+  nc -z -w ` + t + ` ` + host + ` ` + port + ` >/dev/null 2>&1; rc=$?
+elif command -v bash >/dev/null 2>&1; then
+  timeout ` + t + ` bash -c 'exec 3<>/dev/tcp/'` + host + `'/'` + port + ` >/dev/null 2>&1; rc=$?
+else
+  echo "REACH=fail no nc or bash"; exit 0
+fi
+end=$(now)
+if [ "$rc" -eq 0 ]; then
+  case "$start$end" in *N*|"") echo "REACH=ok -1" ;; *) echo "REACH=ok $(( (end - start) / 1000 ))" ;; esac
+else
+  echo "REACH=fail connect exit $rc"
+fi`
+}
+
+// parseTopologyProbe 解析 topologyProbeScript 的输出（runSSH 可能夹带 PTY 的 \r）
+func parseTopologyProbe(out string) (rsync bool, reach ReachProbe) {
+	reach.Error = "no probe output"
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if v, ok := strings.CutPrefix(line, "RSYNC="); ok {
+			rsync = v == "1"
+			continue
+		}
+		v, ok := strings.CutPrefix(line, "REACH=")
+		if !ok {
+			continue
+		}
+		status, detail, _ := strings.Cut(v, " ")
+		if status != "ok" {
+			reach = ReachProbe{Error: detail}
+			continue
+		}
+		reach = ReachProbe{OK: true}
+		if us, err := strconv.ParseFloat(detail, 64); err == nil && us >= 0 {
+			reach.LatencyMs = us / 1000
+		}
+	}
+	return rsync, reach
+}
+
+// hostCacheKey：探测缓存里代表一台主机的键，连接配置变了就是另一个键
+func hostCacheKey(h *Host) string {
+	c := &h.Config
+	return fmt.Sprintf("%s|%s@%s:%d|%s:%d", c.Name, c.User, c.Host, c.Port, c.LanHost, c.LanPort)
+}
+
+func msSince(start time.Time) float64 {
+	return float64(time.Since(start).Microseconds()) / 1000
+}
+
+// chooseExecSide：根据探测结果选执行方式，返回写进 plan 的理由
+func chooseExecSide(t *TopologyProbe) (ExecMode, string) {
+	src, dst := &t.Source, &t.Dest
+	srcOK := src.Rsync && src.Peer.OK
+	dstOK := dst.Rsync && dst.Peer.OK
+
+	switch {
+	case !src.reachable && !dst.reachable:
+		return ExecOnSource, fmt.Sprintf("auto: probe failed on both hosts (%s; %s), defaulting to source", src.Error, dst.Error)
+	case srcOK && dstOK:
+		// 两端都能直连：谁连对方更快谁执行；差不多时（10% 以内）选本机到它往返更快的（日志/进度回传更及时），
+		// 还分不出来就用源机
+		if dst.Peer.LatencyMs > 0 && src.Peer.LatencyMs > dst.Peer.LatencyMs*1.1 {
+			return ExecOnDest, fmt.Sprintf("auto: both hosts can run rsync and reach each other; dest connects faster (%s vs %s)",
+				fmtMs(dst.Peer.LatencyMs), fmtMs(src.Peer.LatencyMs))
+		}
+		if src.Peer.LatencyMs > 0 && dst.Peer.LatencyMs > src.Peer.LatencyMs*1.1 {
+			return ExecOnSource, fmt.Sprintf("auto: both hosts can run rsync and reach each other; source connects faster (%s vs %s)",
+				fmtMs(src.Peer.LatencyMs), fmtMs(dst.Peer.LatencyMs))
+		}
+		if dst.RTTMs > 0 && src.RTTMs > dst.RTTMs*1.1 {
+			return ExecOnDest, fmt.Sprintf("auto: both hosts reach each other equally fast (source %s, dest %s); dest is closer to this machine (RTT %s vs %s)",
+				fmtMs(src.Peer.LatencyMs), fmtMs(dst.Peer.LatencyMs), fmtMs(dst.RTTMs), fmtMs(src.RTTMs))
+		}
+		return ExecOnSource, fmt.Sprintf("auto: both hosts can run rsync and reach each other (source %s, dest %s), using source",
+			fmtMs(src.Peer.LatencyMs), fmtMs(dst.Peer.LatencyMs))
+	case srcOK:
+		return ExecOnSource, "auto: only source can run rsync against the other host (" + describeCandidate(dst) + ")"
+	case dstOK:
+		return ExecOnDest, "auto: only dest can run rsync against the other host (" + describeCandidate(src) + ")"
+	default:
+		return ExecTwoStepLocal, fmt.Sprintf("auto: neither host can sync directly (source: %s; dest: %s), relaying via this machine",
+			describeCandidate(src), describeCandidate(dst))
+	}
+}
+
+// describeCandidate：为什么这一端不能当执行端
+func describeCandidate(p *HostProbe) string {
+	var why []string
+	if !p.reachable {
+		return p.Host + " probe failed: " + p.Error
+	}
+	if !p.Rsync {
+		why = append(why, "no rsync")
+	}
+	if !p.Peer.OK {
+		why = append(why, "cannot reach "+p.Peer.Target+": "+p.Peer.Error)
+	}
+	return p.Host + " " + strings.Join(why, ", ")
+}
+
+func fmtMs(ms float64) string {
+	if ms <= 0 {
+		return "?ms"
+	}
+	return strconv.FormatFloat(ms, 'f', 1, 64) + "ms"
+}
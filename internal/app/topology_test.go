@@ -0,0 +1,58 @@
+package app
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTopologyProbe(t *testing.T) {
+	rsync, reach := parseTopologyProbe("RSYNC=1\r\nREACH=ok 1500\r\n")
+	if !rsync || !reach.OK || reach.LatencyMs != 1.5 {
+		t.Fatalf("got rsync=%v reach=%+v", rsync, reach)
+	}
+	rsync, reach = parseTopologyProbe("RSYNC=0\nREACH=fail connect exit 1\n")
+	if rsync || reach.OK || reach.Error != "connect exit 1" {
+		t.Fatalf("got rsync=%v reach=%+v", rsync, reach)
+	}
+	if _, reach = parseTopologyProbe("RSYNC=1\nREACH=ok -1\n"); !reach.OK || reach.LatencyMs != 0 {
+		t.Fatalf("unknown latency: %+v", reach)
+	}
+}
+
+func TestChooseExecSide(t *testing.T) {
+	probe := func(rsync, ok bool, ms float64) HostProbe {
+		return HostProbe{Host: "h", Rsync: rsync, Peer: ReachProbe{Target: "peer:22", OK: ok, LatencyMs: ms}, reachable: true}
+	}
+	cases := []struct {
+		name     string
+		src, dst HostProbe
+		want     ExecMode
+	}{
+		{"both, similar", probe(true, true, 10), probe(true, true, 9.5), ExecOnSource},
+		{"both, dest faster", probe(true, true, 40), probe(true, true, 5), ExecOnDest},
+		{"source has no rsync", probe(false, true, 1), probe(true, true, 50), ExecOnDest},
+		{"dest cannot reach", probe(true, true, 50), probe(true, false, 0), ExecOnSource},
+		{"firewalled both ways", probe(true, false, 0), probe(true, false, 0), ExecTwoStepLocal},
+		{"probe failed", HostProbe{Error: "dial"}, HostProbe{Error: "dial"}, ExecOnSource},
+	}
+	// 连对方差不多快时，看本机到哪端更近
+	closeDst := probe(true, true, 9.5)
+	closeDst.RTTMs = 2
+	farSrc := probe(true, true, 10)
+	farSrc.RTTMs = 80
+	cases = append(cases, struct {
+		name     string
+		src, dst HostProbe
+		want     ExecMode
+	}{"similar, dest closer to us", farSrc, closeDst, ExecOnDest})
+
+	for _, c := range cases {
+		mode, reason := chooseExecSide(&TopologyProbe{Source: c.src, Dest: c.dst})
+		if mode != c.want {
+			t.Errorf("%s: mode = %s, want %s (%s)", c.name, mode, c.want, reason)
+		}
+		if !strings.HasPrefix(reason, "auto: ") {
+			t.Errorf("%s: reason = %q", c.name, reason)
+		}
+	}
+}
@@ -13,11 +13,18 @@ import (
 )
 
 // UploadLocalFile: 拖拽上传（本机->远端用 Go rsyncclient，不再 exec 本机 rsync）
-func (a *App) UploadLocalFile(hostName, dstDir, relPath, localFile string) error {
+func (a *App) UploadLocalFile(hostName, dstDir, relPath, localFile string) (err error) {
 	h, ok := a.Hosts.Get(hostName)
 	if !ok {
 		return fmt.Errorf("unknown host %q", hostName)
 	}
+	if info, statErr := os.Stat(localFile); statErr == nil {
+		defer func() {
+			if err == nil {
+				metrics.observe(mUploadBytes, float64(info.Size()), hostName)
+			}
+		}()
+	}
 
 	if relPath == "" {
 		relPath = filepath.Base(localFile)
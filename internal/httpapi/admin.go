@@ -0,0 +1,18 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// POST /api/admin/prune[?dryRun=1]：立即按保留策略清理任务历史
+func (s *Server) handleAdminPrune(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	res := s.app.JobManager.PruneJobs(time.Now(), parseBoolish(r.URL.Query().Get("dryRun")))
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,127 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"rsyncgui/internal/app"
+)
+
+const (
+	sseProgressInterval = 500 * time.Millisecond // progress 事件最多这么频繁
+	sseKeepAlive        = 15 * time.Second
+)
+
+// GET /api/jobs/{id}/events?offset=N
+// Server-Sent Events：
+//   - event: log       id=下一行的偏移量，data={"offset":n,"line":"..."}
+//   - event: status    data={"status":"running",...}
+//   - event: progress  data=app.Progress
+//   - event: end       任务结束，随后关闭连接
+//
+// 断线重连时浏览器会带 Last-Event-ID，从那一行接着推。
+func (s *Server) handleJobEvents(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	job, ok := s.app.JobManager.GetJob(id)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	offset := parseIntDefault(r.URL.Query().Get("offset"), 0)
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			offset = n
+		}
+	}
+
+	notify, unsubscribe := job.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	var (
+		lastStatus     app.JobStatus
+		lastProgress   app.Progress
+		lastProgressAt time.Time
+	)
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	// progress 被限频时，保证过一会儿还能把最新值推出去
+	progressTick := time.NewTicker(sseProgressInterval)
+	defer progressTick.Stop()
+
+	for {
+		ev := job.EventsSince(offset)
+		for i, line := range ev.Lines {
+			n := ev.Offset + i
+			writeSSE(w, "log", strconv.Itoa(n+1), struct {
+				Offset int    `json:"offset"`
+				Line   string `json:"line"`
+			}{n, line})
+		}
+		offset = ev.NextOffset
+
+		if ev.Status != lastStatus {
+			lastStatus = ev.Status
+			writeSSE(w, "status", "", struct {
+				Status    app.JobStatus `json:"status"`
+				StartedAt time.Time     `json:"startedAt"`
+				EndedAt   time.Time     `json:"endedAt"`
+			}{ev.Status, ev.StartedAt, ev.EndedAt})
+		}
+		if ev.Progress != lastProgress && (time.Since(lastProgressAt) >= sseProgressInterval || ev.Status.IsFinished()) {
+			lastProgress = ev.Progress
+			lastProgressAt = time.Now()
+			writeSSE(w, "progress", "", ev.Progress)
+		}
+
+		if ev.More {
+			// 从日志文件补旧行：一页一页推完再处理结束/等通知
+			flusher.Flush()
+			continue
+		}
+		if ev.Status.IsFinished() {
+			writeSSE(w, "end", "", struct {
+				Status app.JobStatus `json:"status"`
+			}{ev.Status})
+			flusher.Flush()
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-notify:
+		case <-progressTick.C:
+		case <-keepAlive.C:
+			_, _ = fmt.Fprint(w, ": ping\n\n")
+		}
+	}
+}
+
+func writeSSE(w http.ResponseWriter, event, id string, data any) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	if id != "" {
+		_, _ = fmt.Fprintf(w, "id: %s\n", id)
+	}
+	_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b)
+}
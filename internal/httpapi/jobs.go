@@ -2,42 +2,223 @@ package httpapi
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
+
+	"rsyncgui/internal/app"
 )
 
-// GET /api/jobs
+// GET /api/jobs?status=running,failed&host=&task=&schedule=&pipeline=&since=&until=&q=&order=asc&limit=&cursor=&tail=
+// 返回 app.JobPage；since/until 接受 RFC3339 或 2006-01-02
 func (s *Server) handleJobs(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	jobs := s.app.JobManager.ListJobs()
+	q, err := parseJobQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, "bad query: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := s.app.JobManager.QueryJobs(q)
+	if err != nil {
+		http.Error(w, "bad query: "+err.Error(), http.StatusBadRequest)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(jobs)
+	_ = json.NewEncoder(w).Encode(page)
+}
+
+func parseJobQuery(v url.Values) (app.JobQuery, error) {
+	q := app.JobQuery{
+		Host:     v.Get("host"),
+		Task:     v.Get("task"),
+		Schedule: v.Get("schedule"),
+		Pipeline: v.Get("pipeline"),
+		FanOut:   v.Get("fanout"),
+		Search:   v.Get("q"),
+		Asc:      v.Get("order") == "asc",
+		Limit:    parseIntDefault(v.Get("limit"), 0),
+		Cursor:   v.Get("cursor"),
+		LogTail:  parseIntDefault(v.Get("tail"), 0),
+	}
+	if st := v.Get("status"); st != "" {
+		for _, p := range strings.Split(st, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				q.Statuses = append(q.Statuses, app.JobStatus(p))
+			}
+		}
+	}
+	var err error
+	if q.Since, err = parseQueryTime(v.Get("since")); err != nil {
+		return q, fmt.Errorf("since: %w", err)
+	}
+	if q.Until, err = parseQueryTime(v.Get("until")); err != nil {
+		return q, fmt.Errorf("until: %w", err)
+	}
+	return q, nil
+}
+
+func parseQueryTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", v, time.Local)
 }
 
 // GET /api/jobs/{id}
+// DELETE /api/jobs/{id}：取消任务
+// GET /api/jobs/{id}/events：SSE
+// GET /api/jobs/{id}/log?offset=&limit=：分页读日志
+// GET /api/jobs/{id}/log/raw：下载完整日志
+// POST /api/jobs/{id}/rerun：用原请求重新跑一次
+// POST /api/jobs/{id}/clone：同上，body 里的字段覆盖原请求
 func (s *Server) handleJobDetail(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
 	prefix := "/api/jobs/"
 	if !strings.HasPrefix(r.URL.Path, prefix) {
 		http.NotFound(w, r)
 		return
 	}
-	id := strings.TrimPrefix(r.URL.Path, prefix)
+	id, sub, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, prefix), "/")
 	if id == "" {
 		http.NotFound(w, r)
 		return
 	}
+
+	switch sub {
+	case "":
+	case "events":
+		s.handleJobEvents(w, r, id)
+		return
+	case "log":
+		s.handleJobLog(w, r, id)
+		return
+	case "log/raw":
+		s.handleJobLogRaw(w, r, id)
+		return
+	case "rerun", "clone":
+		s.handleJobRerun(w, r, id, sub == "clone")
+		return
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		job, ok := s.app.JobManager.GetJob(id)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(job.Snapshot(-1))
+	case http.MethodDelete:
+		s.handleJobCancel(w, r, id)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleJobCancel(w http.ResponseWriter, r *http.Request, id string) {
+	job, err := s.app.JobManager.CancelJob(id)
+	switch {
+	case errors.Is(err, app.ErrJobNotFound):
+		http.NotFound(w, r)
+		return
+	case errors.Is(err, app.ErrJobNotActive):
+		http.Error(w, "cancel error: "+err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "cancel error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(struct {
+		JobID string `json:"jobId"`
+	}{
+		JobID: job.ID,
+	})
+}
+
+func (s *Server) handleJobLog(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 	job, ok := s.app.JobManager.GetJob(id)
 	if !ok {
 		http.NotFound(w, r)
 		return
 	}
+	q := r.URL.Query()
+	page, err := job.ReadLog(parseIntDefault(q.Get("offset"), 0), parseIntDefault(q.Get("limit"), 500))
+	if err != nil {
+		http.Error(w, "read log error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(job)
+	_ = json.NewEncoder(w).Encode(page)
+}
+
+func (s *Server) handleJobLogRaw(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	job, ok := s.app.JobManager.GetJob(id)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	rc, err := job.OpenLog()
+	if err != nil {
+		http.Error(w, "open log error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "job-"+id+".log"))
+	_, _ = io.Copy(w, rc)
+}
+
+func (s *Server) handleJobRerun(w http.ResponseWriter, r *http.Request, id string, withOverride bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	parent, ok := s.app.JobManager.GetJob(id)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	var patch []byte
+	if withOverride {
+		b, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			http.Error(w, "read body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		patch = b
+	}
+	req, err := parent.CloneRequest(patch)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sub, err := s.app.SubmitTransfer(req, app.SubmitOptions{ParentID: parent.ID, Task: parent.Task})
+	writeSubmission(w, sub, err)
 }
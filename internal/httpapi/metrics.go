@@ -0,0 +1,13 @@
+package httpapi
+
+import "net/http"
+
+// GET /metrics：Prometheus 抓取
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = s.app.WriteMetrics(w)
+}
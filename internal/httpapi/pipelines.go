@@ -0,0 +1,88 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"rsyncgui/internal/app"
+)
+
+// GET /api/pipelines：流水线运行列表
+// POST /api/pipelines：提交并开始执行，body: app.PipelineDef
+func (s *Server) handlePipelines(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		list := s.app.Pipelines.List()
+		out := make([]*app.Pipeline, 0, len(list))
+		for _, p := range list {
+			out = append(out, p.Snapshot())
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(out)
+	case http.MethodPost:
+		var def app.PipelineDef
+		if err := json.NewDecoder(r.Body).Decode(&def); err != nil {
+			http.Error(w, "invalid json: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		p, err := s.app.Pipelines.Start(def)
+		if err != nil {
+			if errors.Is(err, app.ErrPipelineInvalid) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			http.Error(w, "start pipeline error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(p.Snapshot())
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET /api/pipelines/{id}
+// DELETE /api/pipelines/{id}：取消整条流水线
+func (s *Server) handlePipelineDetail(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/pipelines/")
+	if id == "" || strings.Contains(id, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		p, ok := s.app.Pipelines.Get(id)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(p.Snapshot())
+	case http.MethodDelete:
+		p, err := s.app.Pipelines.Cancel(id)
+		switch {
+		case errors.Is(err, app.ErrPipelineNotFound):
+			http.NotFound(w, r)
+			return
+		case errors.Is(err, app.ErrPipelineNotActive):
+			http.Error(w, "cancel error: "+err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, "cancel error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		_ = json.NewEncoder(w).Encode(struct {
+			PipelineID string `json:"pipelineId"`
+		}{
+			PipelineID: p.ID,
+		})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,138 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"rsyncgui/internal/app"
+)
+
+// GET /api/schedules：列出定时（含下次/上次运行）
+// POST /api/schedules：新建，body: app.Schedule
+func (s *Server) handleSchedules(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(s.app.Schedules.List())
+	case http.MethodPost:
+		var def app.Schedule
+		if err := json.NewDecoder(r.Body).Decode(&def); err != nil {
+			http.Error(w, "invalid json: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		st, err := s.app.Schedules.Create(def)
+		if err != nil {
+			writeScheduleError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(st)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET/PUT/DELETE /api/schedules/{name}
+// GET /api/schedules/{name}/next?n=5：接下来 n 次触发时间
+func (s *Server) handleScheduleDetail(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.EscapedPath(), "/api/schedules/")
+	rawName, sub, _ := strings.Cut(rest, "/")
+	name, err := url.PathUnescape(rawName)
+	if err != nil || name == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch sub {
+	case "":
+	case "next":
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		st, ok := s.app.Schedules.Get(name)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		writeNextRuns(w, st.Cron, st.Timezone, parseIntDefault(r.URL.Query().Get("n"), 5))
+		return
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		st, ok := s.app.Schedules.Get(name)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(st)
+	case http.MethodPut:
+		var def app.Schedule
+		if err := json.NewDecoder(r.Body).Decode(&def); err != nil {
+			http.Error(w, "invalid json: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		st, err := s.app.Schedules.Update(name, def)
+		if err != nil {
+			writeScheduleError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(st)
+	case http.MethodDelete:
+		if err := s.app.Schedules.Delete(name); err != nil {
+			writeScheduleError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET /api/cron/preview?cron=30+2+*+*+*&timezone=Asia/Shanghai&n=5：保存前预览表达式
+func (s *Server) handleCronPreview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	writeNextRuns(w, q.Get("cron"), q.Get("timezone"), parseIntDefault(q.Get("n"), 5))
+}
+
+func writeNextRuns(w http.ResponseWriter, expr, tz string, n int) {
+	runs, err := app.NextRuns(expr, tz, time.Now(), n)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(struct {
+		Next []time.Time `json:"next"`
+	}{
+		Next: runs,
+	})
+}
+
+func writeScheduleError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, app.ErrScheduleNotFound):
+		http.NotFound(w, r)
+	case errors.Is(err, app.ErrScheduleExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, app.ErrScheduleInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "save schedule error: "+err.Error(), http.StatusInternalServerError)
+	}
+}
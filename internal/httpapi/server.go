@@ -30,12 +30,22 @@ func (s *Server) routes() {
 	s.mux.HandleFunc("/api/preview", s.handlePreview)
 	s.mux.HandleFunc("/api/jobs", s.handleJobs)
 	s.mux.HandleFunc("/api/jobs/", s.handleJobDetail) // /api/jobs/{id}
+	s.mux.HandleFunc("/api/tasks", s.handleTasks)
+	s.mux.HandleFunc("/api/tasks/", s.handleTaskDetail) // /api/tasks/{name}
+	s.mux.HandleFunc("/api/schedules", s.handleSchedules)
+	s.mux.HandleFunc("/api/schedules/", s.handleScheduleDetail) // /api/schedules/{name}
+	s.mux.HandleFunc("/api/cron/preview", s.handleCronPreview)
+	s.mux.HandleFunc("/api/pipelines", s.handlePipelines)
+	s.mux.HandleFunc("/api/pipelines/", s.handlePipelineDetail) // /api/pipelines/{id}
 	s.mux.HandleFunc("/api/upload", s.handleUpload)
 	s.mux.HandleFunc("/api/pathinfo", s.handlePathInfo)
 
 	s.mux.HandleFunc("/api/fs/home", s.handleFSHome)
 	s.mux.HandleFunc("/api/fs/list", s.handleFSList)
 
+	s.mux.HandleFunc("/api/admin/prune", s.handleAdminPrune)
+	s.mux.HandleFunc("/metrics", s.handleMetrics)
+
 	// 前端静态文件（embed 或外置，由 uiembed 的 build tag 决定）
 	distFS, err := uiembed.DistFS()
 	if err != nil {
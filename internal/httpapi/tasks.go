@@ -0,0 +1,184 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"rsyncgui/internal/app"
+)
+
+// GET /api/tasks：列出保存的 task
+// POST /api/tasks：新建，body: app.TaskDef
+func (s *Server) handleTasks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		tasks := s.app.Tasks.List()
+		out := make([]app.TaskDef, 0, len(tasks))
+		for _, t := range tasks {
+			out = append(out, redactTask(t))
+		}
+		_ = json.NewEncoder(w).Encode(out)
+	case http.MethodPost:
+		t, ok := s.decodeTask(w, r)
+		if !ok {
+			return
+		}
+		if err := s.app.Tasks.Create(t); err != nil {
+			writeTaskError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(redactTask(t))
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET/PUT/DELETE /api/tasks/{name}
+// POST /api/tasks/{name}/run：用这个 task 创建并启动一个 Job
+func (s *Server) handleTaskDetail(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.EscapedPath(), "/api/tasks/")
+	rawName, sub, _ := strings.Cut(rest, "/")
+	name, err := url.PathUnescape(rawName)
+	if err != nil || name == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch sub {
+	case "":
+	case "run":
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		res, err := s.app.RunTask(name, app.SubmitOptions{})
+		if errors.Is(err, app.ErrTaskNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		writeSubmission(w, res, err)
+		return
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		t, ok := s.app.Tasks.Get(name)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(redactTask(t))
+	case http.MethodPut:
+		t, ok := s.decodeTask(w, r)
+		if !ok {
+			return
+		}
+		if old, ok := s.app.Tasks.Get(name); ok {
+			keepTaskSecrets(&t, old)
+		}
+		if err := s.app.UpdateTask(name, t); err != nil {
+			writeTaskError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(redactTask(t))
+	case http.MethodDelete:
+		if err := s.app.DeleteTask(name); err != nil {
+			writeTaskError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// secretMask：返回给前端时代替 webhook 请求头的值；PUT 原样带回表示不修改
+const secretMask = "******"
+
+// redactTask：返回给前端的拷贝，去掉通知配置里的 SMTP 密码、遮住 webhook 请求头（可能带 token）
+func redactTask(t app.TaskDef) app.TaskDef {
+	if t.Notify == nil {
+		return t
+	}
+	n := *t.Notify
+	n.Webhooks = make([]app.WebhookTarget, len(t.Notify.Webhooks))
+	for i, wh := range t.Notify.Webhooks {
+		if len(wh.Headers) > 0 {
+			masked := make(map[string]string, len(wh.Headers))
+			for k := range wh.Headers {
+				masked[k] = secretMask
+			}
+			wh.Headers = masked
+		}
+		n.Webhooks[i] = wh
+	}
+	if n.Email != nil {
+		e := *n.Email
+		e.Password = ""
+		n.Email = &e
+	}
+	t.Notify = &n
+	return t
+}
+
+// keepTaskSecrets：PUT 时密码留空、请求头值是 secretMask 的，沿用已保存的值（webhook 按 URL 对应）
+func keepTaskSecrets(t *app.TaskDef, old app.TaskDef) {
+	if t.Notify == nil || old.Notify == nil {
+		return
+	}
+	if e, oe := t.Notify.Email, old.Notify.Email; e != nil && oe != nil && e.Password == "" {
+		e.Password = oe.Password
+	}
+	for i := range t.Notify.Webhooks {
+		wh := &t.Notify.Webhooks[i]
+		for _, owh := range old.Notify.Webhooks {
+			if owh.URL != wh.URL {
+				continue
+			}
+			for k, v := range wh.Headers {
+				if v == secretMask {
+					wh.Headers[k] = owh.Headers[k]
+				}
+			}
+			break
+		}
+	}
+}
+
+// decodeTask 解析 body 并确认请求本身能生成执行计划（不探测主机，执行时再探测）
+func (s *Server) decodeTask(w http.ResponseWriter, r *http.Request) (app.TaskDef, bool) {
+	var t app.TaskDef
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		http.Error(w, "invalid json: "+err.Error(), http.StatusBadRequest)
+		return t, false
+	}
+	if err := s.app.ValidateTransfer(t.TransferRequest); err != nil {
+		http.Error(w, "plan error: "+err.Error(), http.StatusBadRequest)
+		return t, false
+	}
+	return t, true
+}
+
+func writeTaskError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, app.ErrTaskNotFound):
+		http.NotFound(w, r)
+	case errors.Is(err, app.ErrTaskExists), errors.Is(err, app.ErrTaskInUse):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, app.ErrTaskName):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "save task error: "+err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -2,6 +2,7 @@ package httpapi
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"rsyncgui/internal/app" // ← 这里按你自己的 module 路径改，比如 "github.com/xxx/rsyncgui/internal/app"
@@ -21,46 +22,44 @@ func (s *Server) handleTransfers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 1. 生成执行计划（决定 source/dest + ExecLocal / OnSource / OnDest）
-	plan, err := s.app.PlanTransfer(req)
-	if err != nil {
-		http.Error(w, "plan error: "+err.Error(), http.StatusBadRequest)
-		return
-	}
+	// 生成执行计划 → 预检查（源是否可读、目标是否可写）→ 创建 Job 并异步启动
+	sub, err := s.app.SubmitTransfer(req, app.SubmitOptions{})
+	writeSubmission(w, sub, err)
+}
 
-	// 2. 做一轮预检查：源是否可读、目标是否可写
-	precheck, err := s.app.RunPrechecks(plan)
-	if err != nil {
-		http.Error(w, "precheck error: "+err.Error(), http.StatusInternalServerError)
+// writeSubmission：返回 Job 信息 + 预检查结果；预检查不过时不创建任务，把结果返回给前端
+func writeSubmission(w http.ResponseWriter, sub *app.Submission, err error) {
+	var se *app.SubmitError
+	switch {
+	case errors.As(err, &se) && se.Stage == "plan":
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
-	}
-	if !precheck.SourceReadable || !precheck.DestWritable {
-		// 预检查不过，直接返回给前端，不创建任务
+	case errors.Is(err, app.ErrPrecheckFailed):
 		w.Header().Set("Content-Type", "application/json")
 		_ = json.NewEncoder(w).Encode(struct {
 			Error    string              `json:"error"`
 			Precheck *app.PrecheckResult `json:"precheck"`
 		}{
 			Error:    "precheck failed",
-			Precheck: precheck,
+			Precheck: sub.Precheck,
 		})
 		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	// 3. 创建 Job 并异步启动
-	job := s.app.JobManager.NewJob(req, plan)
-	s.app.JobManager.StartJob(job)
-
-	// 4. 返回 Job 信息 + 预检查结果
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(struct {
 		JobID    string              `json:"jobId"`
+		ParentID string              `json:"parentId,omitempty"`
 		Plan     *app.TransferPlan   `json:"plan"`
 		Precheck *app.PrecheckResult `json:"precheck"`
 	}{
-		JobID:    job.ID,
-		Plan:     plan,
-		Precheck: precheck,
+		JobID:    sub.Job.ID,
+		ParentID: sub.Job.ParentID,
+		Plan:     sub.Plan,
+		Precheck: sub.Precheck,
 	})
 }
 
@@ -77,7 +76,7 @@ func (s *Server) handlePreview(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	plan, err := s.app.PlanTransfer(req)
+	plan, err := s.app.PlanTransferContext(r.Context(), req)
 	if err != nil {
 		http.Error(w, "plan error: "+err.Error(), http.StatusBadRequest)
 		return
@@ -92,7 +91,9 @@ func (s *Server) handlePreview(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(struct {
 		Command string `json:"command"`
+		Reason  string `json:"reason,omitempty"` // execSide=auto 选执行端的理由
 	}{
 		Command: cmd,
+		Reason:  plan.Reason,
 	})
 }
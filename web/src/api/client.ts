@@ -4,7 +4,14 @@ import {
     CreateTransferResponse,
     PreviewResponse,
     Job,
+    JobPage,
+    JobQuery,
     FSListResult,
+    TaskDef,
+    Schedule,
+    ScheduleStatus,
+    Pipeline,
+    PipelineDef,
 } from "../types/api";
 
 async function jsonFetch<T>(url: string, init?: RequestInit): Promise<T> {
@@ -64,14 +71,111 @@ export const api = {
         });
     },
 
-    async listJobs(): Promise<Job[]> {
-        return jsonFetch<Job[]>("/api/jobs");
+    async listJobs(query?: JobQuery): Promise<JobPage> {
+        const params = new URLSearchParams();
+        if (query) {
+            for (const [k, v] of Object.entries(query)) {
+                if (v !== undefined && v !== "") params.set(k, String(v));
+            }
+        }
+        const qs = params.toString();
+        return jsonFetch<JobPage>(qs ? `/api/jobs?${qs}` : "/api/jobs");
     },
 
     async getJob(id: string): Promise<Job> {
         return jsonFetch<Job>(`/api/jobs/${id}`);
     },
 
+    async rerunJob(id: string): Promise<CreateTransferResponse> {
+        return jsonFetch<CreateTransferResponse>(`/api/jobs/${id}/rerun`, { method: "POST" });
+    },
+
+    async cloneJob(id: string, override: Partial<TransferRequest>): Promise<CreateTransferResponse> {
+        return jsonFetch<CreateTransferResponse>(`/api/jobs/${id}/clone`, {
+            method: "POST",
+            body: JSON.stringify(override)
+        });
+    },
+
+    async listTasks(): Promise<TaskDef[]> {
+        return jsonFetch<TaskDef[]>("/api/tasks");
+    },
+
+    async saveTask(task: TaskDef, originalName?: string): Promise<TaskDef> {
+        if (originalName) {
+            return jsonFetch<TaskDef>(`/api/tasks/${encodeURIComponent(originalName)}`, {
+                method: "PUT",
+                body: JSON.stringify(task)
+            });
+        }
+        return jsonFetch<TaskDef>("/api/tasks", {
+            method: "POST",
+            body: JSON.stringify(task)
+        });
+    },
+
+    async deleteTask(name: string): Promise<void> {
+        const res = await fetch(`/api/tasks/${encodeURIComponent(name)}`, { method: "DELETE" });
+        if (!res.ok) {
+            throw new Error(`HTTP ${res.status}: ${await res.text()}`);
+        }
+    },
+
+    async runTask(name: string): Promise<CreateTransferResponse> {
+        return jsonFetch<CreateTransferResponse>(`/api/tasks/${encodeURIComponent(name)}/run`, { method: "POST" });
+    },
+
+    async listSchedules(): Promise<ScheduleStatus[]> {
+        return jsonFetch<ScheduleStatus[]>("/api/schedules");
+    },
+
+    async saveSchedule(schedule: Schedule, originalName?: string): Promise<ScheduleStatus> {
+        if (originalName) {
+            return jsonFetch<ScheduleStatus>(`/api/schedules/${encodeURIComponent(originalName)}`, {
+                method: "PUT",
+                body: JSON.stringify(schedule)
+            });
+        }
+        return jsonFetch<ScheduleStatus>("/api/schedules", {
+            method: "POST",
+            body: JSON.stringify(schedule)
+        });
+    },
+
+    async deleteSchedule(name: string): Promise<void> {
+        const res = await fetch(`/api/schedules/${encodeURIComponent(name)}`, { method: "DELETE" });
+        if (!res.ok) {
+            throw new Error(`HTTP ${res.status}: ${await res.text()}`);
+        }
+    },
+
+    async previewCron(cron: string, timezone: string, n = 5): Promise<{ next: string[] }> {
+        const qs = new URLSearchParams({ cron, timezone, n: String(n) });
+        return jsonFetch<{ next: string[] }>(`/api/cron/preview?${qs}`);
+    },
+
+    async listPipelines(): Promise<Pipeline[]> {
+        return jsonFetch<Pipeline[]>("/api/pipelines");
+    },
+
+    async startPipeline(def: PipelineDef): Promise<Pipeline> {
+        return jsonFetch<Pipeline>("/api/pipelines", {
+            method: "POST",
+            body: JSON.stringify(def)
+        });
+    },
+
+    async getPipeline(id: string): Promise<Pipeline> {
+        return jsonFetch<Pipeline>(`/api/pipelines/${id}`);
+    },
+
+    async cancelPipeline(id: string): Promise<void> {
+        const res = await fetch(`/api/pipelines/${id}`, { method: "DELETE" });
+        if (!res.ok) {
+            throw new Error(`HTTP ${res.status}: ${await res.text()}`);
+        }
+    },
+
     async uploadFile(params: {
         file: File;
         hostName: string;
@@ -1,21 +1,23 @@
 import React from "react";
-import { Job } from "../types/api";
+import { JobSummary } from "../types/api";
 import { useTranslation } from "react-i18next";
 
 interface Props {
-    jobs: Job[];
+    jobs: JobSummary[];
     onRefresh: () => void;
+    onRerun?: (id: string) => void;
 }
 
-const statusColor: Record<Job["status"], string> = {
+const statusColor: Record<JobSummary["status"], string> = {
     pending: "#aaa",
     running: "#ffd166",
     success: "#06d6a0",
     failed: "#ef476f",
-    cancelled: "#888"
+    cancelled: "#888",
+    interrupted: "#f78c6b"
 };
 
-const JobsPanel: React.FC<Props> = ({ jobs, onRefresh }) => {
+const JobsPanel: React.FC<Props> = ({ jobs, onRefresh, onRerun }) => {
     const { t } = useTranslation();
 
     const fmtExecHost = (execHost: string) => {
@@ -23,7 +25,7 @@ const JobsPanel: React.FC<Props> = ({ jobs, onRefresh }) => {
         return execHost;
     };
 
-    const fmtMode = (mode: Job["plan"]["mode"]) => {
+    const fmtMode = (mode: JobSummary["plan"]["mode"]) => {
         switch (mode) {
             case "local":
                 return t("jobs_panel.mode_local");
@@ -33,6 +35,10 @@ const JobsPanel: React.FC<Props> = ({ jobs, onRefresh }) => {
                 return t("jobs_panel.mode_on_dest");
             case "two_step_local":
                 return t("jobs_panel.mode_two_step_local");
+            case "relay_stream":
+                return t("jobs_panel.mode_relay_stream");
+            case "via_host":
+                return t("jobs_panel.mode_via_host");
             default:
                 return mode;
         }
@@ -62,7 +68,15 @@ const JobsPanel: React.FC<Props> = ({ jobs, onRefresh }) => {
                                     style={{ backgroundColor: statusColor[job.status] }}
                                 ></span>
                                 <span className="job-status-text">{job.status}</span>
-                                <span className="job-mode-tag">
+                                {job.parentId && (
+                                    <span className="job-parent">↻ {job.parentId.slice(0, 8)}</span>
+                                )}
+                                {onRerun && job.status !== "pending" && job.status !== "running" && (
+                                    <button className="small-btn" onClick={() => onRerun(job.id)}>
+                                        Rerun
+                                    </button>
+                                )}
+                                <span className="job-mode-tag" title={job.plan.reason}>
                                     {t("jobs_panel.exec_on")}: {fmtExecHost(job.plan.execHost)} ·{" "}
                                     {t("jobs_panel.mode")}: {fmtMode(job.plan.mode)}
                 </span>
@@ -82,7 +96,7 @@ const JobsPanel: React.FC<Props> = ({ jobs, onRefresh }) => {
                                     <summary>Logs</summary>
                                     <pre>
                     {job.logLines.slice(-50).join("\n")}
-                                        {job.logLines.length > 50 ? "\n..." : ""}
+                                        {job.logTotal > 50 ? "\n..." : ""}
                   </pre>
                                 </details>
                             )}
@@ -1,8 +1,9 @@
 import React, { useEffect, useMemo, useState } from "react";
-import { RsyncOptions } from "../types/api";
+import { HostInfo, RsyncOptions } from "../types/api";
 import { useTranslation } from "react-i18next";
 
-export type ExecSide = "auto" | "source" | "dest";
+// 除了固定的几种，也可以是第三台主机的名字（经该主机中转）
+export type ExecSide = "auto" | "source" | "dest" | "local" | "stream" | (string & {});
 
 interface Props {
     value: RsyncOptions;
@@ -11,6 +12,9 @@ interface Props {
     // ✅ 远程↔远程时选择第一跳在哪边执行
     execSide: ExecSide;
     onExecSideChange: (v: ExecSide) => void;
+
+    // 可以当中转的远程主机
+    hosts?: HostInfo[];
 }
 
 // 支持简单引号：--exclude='a b' 或 "--exclude=a b"
@@ -56,10 +60,14 @@ const TransferOptions: React.FC<Props> = ({
                                               onChange,
                                               execSide,
                                               onExecSideChange,
+                                              hosts = [],
                                           }) => {
     const { t } = useTranslation();
     const set = (patch: Partial<RsyncOptions>) => onChange({ ...value, ...patch });
 
+    const relayHosts = hosts.filter((h) => !h.isLocal);
+    const viaHost = !["auto", "source", "dest", "local", "stream"].includes(execSide);
+
     // ✅ 关键：不要直接 value.extraArgs.join(" ") 作为受控输入，否则末尾空格会被吞
     const [extraArgsText, setExtraArgsText] = useState<string>("");
 
@@ -113,6 +121,8 @@ const TransferOptions: React.FC<Props> = ({
                             { k: "auto", t: t("transfer_options.exec_side_auto") },
                             { k: "source", t: t("transfer_options.exec_side_source") },
                             { k: "dest", t: t("transfer_options.exec_side_dest") },
+                            { k: "local", t: t("transfer_options.exec_side_local") },
+                            { k: "stream", t: t("transfer_options.exec_side_stream") },
                         ] as const
                     ).map((p) => (
                         <button
@@ -124,6 +134,18 @@ const TransferOptions: React.FC<Props> = ({
                             {p.t}
                         </button>
                     ))}
+                    <select
+                        className={"pill-btn" + (viaHost ? " selected" : "")}
+                        value={viaHost ? execSide : ""}
+                        onChange={(e) => onExecSideChange(e.target.value || "auto")}
+                    >
+                        <option value="">{t("transfer_options.exec_side_via_host")}</option>
+                        {relayHosts.map((h) => (
+                            <option key={h.name} value={h.name}>
+                                {h.name}
+                            </option>
+                        ))}
+                    </select>
                 </div>
             </div>
 
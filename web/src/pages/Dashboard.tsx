@@ -1,10 +1,10 @@
 import React, { useEffect, useState } from "react";
 import EndpointPanel from "../components/EndpointPanel";
 import DirectionSwitch from "../components/DirectionSwitch";
-import TransferOptions from "../components/TransferOptions";
+import TransferOptions, { ExecSide } from "../components/TransferOptions";
 import JobsPanel from "../components/JobsPanel";
 import { api } from "../api/client";
-import { HostInfo, Endpoint, RsyncOptions, TransferRequest, Job } from "../types/api";
+import { HostInfo, Endpoint, RsyncOptions, TransferRequest, JobSummary } from "../types/api";
 import { useTheme } from "../context/ThemeContext";
 import { useTranslation } from "react-i18next";
 
@@ -21,7 +21,7 @@ const Dashboard: React.FC = () => {
     const [endpointA, setEndpointA] = useState<Endpoint>({ hostName: "local", path: "" });
     const [endpointB, setEndpointB] = useState<Endpoint>({ hostName: "", path: "" });
 
-    const [execSide, setExecSide] = useState<"auto" | "source" | "dest">("auto");
+    const [execSide, setExecSide] = useState<ExecSide>("auto");
 
     const [options, setOptions] = useState<RsyncOptions>({
         profile: "LAN",
@@ -33,40 +33,11 @@ const Dashboard: React.FC = () => {
         extraArgs: ["--progress"],
     });
 
-    const [jobs, setJobs] = useState<Job[]>([]);
+    const [jobs, setJobs] = useState<JobSummary[]>([]);
     const [creating, setCreating] = useState(false);
     const [previewData, setPreviewData] = useState<string | null>(null);
     const [previewing, setPreviewing] = useState(false);
 
-    // --- path utils（和 EndpointPanel 一致的风格，避免 Windows/WSL 拼错） ---
-    function pathStyle(p: string): "win" | "posix" {
-        if (!p) return "posix";
-        if (/^[A-Za-z]:/.test(p)) return "win";
-        if (p.startsWith("\\\\")) return "win";
-        if (p.startsWith("/")) return "posix";
-        return "posix";
-    }
-    function trimTrailingSepSmart(p: string): string {
-        if (!p) return p;
-        const style = pathStyle(p);
-        if (style === "win") {
-            if (/^[A-Za-z]:[\\/]{1}$/.test(p)) return p;
-            return p.replace(/[\\/]+$/, "");
-        }
-        return p === "/" ? "/" : p.replace(/\/+$/, "");
-    }
-    function joinPathSmart(base: string, name: string): string {
-        if (!base) return name;
-        const b = trimTrailingSepSmart(base);
-        const style = pathStyle(b);
-        if (style === "win") return b + "\\" + name;
-        return (b === "/" ? "" : b) + "/" + name;
-    }
-    const joinPath = (dir: string, name: string | null): string => {
-        if (!name || name === "..") return dir;
-        return joinPathSmart(dir, name);
-    };
-
     const uniqNames = (arr: string[]) =>
         Array.from(new Set(arr)).filter((n) => n && n !== "..");
 
@@ -90,8 +61,17 @@ const Dashboard: React.FC = () => {
 
     const refreshJobs = async () => {
         try {
-            const js = await api.listJobs();
-            setJobs(js);
+            const page = await api.listJobs({ tail: 50 });
+            setJobs(page.jobs);
+        } catch (err) {
+            console.error(err);
+        }
+    };
+
+    const rerunJob = async (id: string) => {
+        try {
+            await api.rerunJob(id);
+            await refreshJobs();
         } catch (err) {
             console.error(err);
         }
@@ -102,30 +82,18 @@ const Dashboard: React.FC = () => {
         return () => clearInterval(id);
     }, []);
 
-    const buildRequests = () => {
+    // 源端选中的多项放进同一个任务（endpoint.files），不再每项一个任务
+    const buildRequests = (): TransferRequest[] => {
         const srcNames = direction === "A_to_B" ? uniqNames(selectedA) : uniqNames(selectedB);
-        const namesToSubmit: (string | null)[] = srcNames.length ? srcNames : [null];
-
-        return namesToSubmit.map((name) => {
-            let finalEndpointA: Endpoint = endpointA;
-            let finalEndpointB: Endpoint = endpointB;
-
-            if (direction === "A_to_B") {
-                finalEndpointA = { ...endpointA, path: joinPath(endpointA.path, name) };
-                finalEndpointB = { ...endpointB, path: endpointB.path };
-            } else {
-                finalEndpointA = { ...endpointA, path: endpointA.path };
-                finalEndpointB = { ...endpointB, path: joinPath(endpointB.path, name) };
-            }
-
-            return {
-                endpointA: finalEndpointA,
-                endpointB: finalEndpointB,
-                direction,
-                execSide,
-                options,
-            };
-        });
+        const files = srcNames.length ? srcNames : undefined;
+
+        return [{
+            endpointA: direction === "A_to_B" ? { ...endpointA, files } : endpointA,
+            endpointB: direction === "B_to_A" ? { ...endpointB, files } : endpointB,
+            direction,
+            execSide,
+            options,
+        }];
     };
 
     const handleCreateTransfer = async () => {
@@ -191,7 +159,7 @@ const Dashboard: React.FC = () => {
         setPreviewing(true);
         try {
             const results = await Promise.all(reqs.map(r => api.previewTransfer(r)));
-            const cmds = results.map(r => r.command).join("\n\n");
+            const cmds = results.map(r => (r.reason ? `# ${r.reason}\n` : "") + r.command).join("\n\n");
             setPreviewData(cmds);
         } catch (err: any) {
             alert(err.message || String(err));
@@ -287,6 +255,7 @@ const Dashboard: React.FC = () => {
                             onChange={setOptions}
                             execSide={execSide}
                             onExecSideChange={setExecSide}
+                            hosts={hosts}
                         />
                     </div>
 
@@ -303,7 +272,7 @@ const Dashboard: React.FC = () => {
                 </section>
 
                 <section className="layout-row">
-                    <JobsPanel jobs={jobs} onRefresh={refreshJobs} />
+                    <JobsPanel jobs={jobs} onRefresh={refreshJobs} onRerun={rerunJob} />
                 </section>
             </main>
 
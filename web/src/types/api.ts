@@ -10,6 +10,7 @@ export interface HostInfo {
 export interface Endpoint {
     hostName: string;
     path: string;
+    files?: string[]; // 只传 path 下选中的这些项（相对路径）
 }
 
 export interface RsyncOptions {
@@ -22,7 +23,7 @@ export interface RsyncOptions {
     extraArgs: string[];
 }
 
-export type ExecSide = "auto" | "source" | "dest";
+export type ExecSide = "auto" | "source" | "dest" | "local" | "stream" | (string & {}); // local = 经本机中转，stream = 本机流式中转（不落盘），其他 = 经该主机中转
 
 export interface TransferRequest {
     endpointA: Endpoint;
@@ -31,13 +32,29 @@ export interface TransferRequest {
     execSide?: ExecSide;
 
     options: RsyncOptions;
+    priority?: number;
+    retry?: RetryPolicy;
+    keepStaging?: boolean; // execSide=local 时保留本机 staging 作为增量缓存
+    preCommand?: HookCommand;
+    postCommand?: HookCommand;
+    fanOut?: FanOutSpec; // 同步到多个目标：另一端留空，生成父任务 + 每个目标一个子任务
+}
+
+export interface RetryPolicy {
+    maxAttempts: number;
+    backoffSeconds?: number;
+    maxBackoff?: number;
+    partialDir?: string;
 }
 
 export type ExecMode =
     | "local"
     | "on_source"
     | "on_dest"
-    | "two_step_local";
+    | "two_step_local"
+    | "relay_stream"
+    | "via_host"
+    | "fan_out";
 
 export interface TransferPlan {
     mode: ExecMode;
@@ -46,6 +63,41 @@ export interface TransferPlan {
     execHost: string;
     twoStep: boolean;
     createdAt: string;
+    reason?: string;
+    topology?: TopologyProbe;
+    hops?: HopDial[]; // 每一跳连 LAN 还是 WAN 地址
+}
+
+export interface HopDial {
+    from: string; // "local" 或发起连接的主机名
+    to: string;
+    host: string;
+    port: number;
+    lan: boolean;
+    latencyMs?: number;
+    reason?: string;
+}
+
+export interface ReachProbe {
+    target: string;
+    ok: boolean;
+    latencyMs?: number;
+    error?: string;
+}
+
+export interface HostProbe {
+    host: string;
+    rsync: boolean;
+    rttMs: number;
+    peer: ReachProbe;
+    error?: string;
+}
+
+// execSide=auto 时的拓扑探测结果
+export interface TopologyProbe {
+    source: HostProbe;
+    dest: HostProbe;
+    probedAt: string;
 }
 
 export interface PrecheckResult {
@@ -59,10 +111,16 @@ export type JobStatus =
     | "running"
     | "success"
     | "failed"
-    | "cancelled";
+    | "cancelled"
+    | "interrupted";
 
 export interface Job {
     id: string;
+    parentId?: string;
+    task?: string;
+    schedule?: string;
+    pipeline?: string;
+    fanOut?: string;
     request: TransferRequest;
     plan: TransferPlan;
     status: JobStatus;
@@ -70,16 +128,201 @@ export interface Job {
     startedAt: string;
     endedAt: string;
     logLines: string[];
+    logTotal?: number;
+    attempts?: JobAttempt[];
+    progress?: Progress;
+    stats?: TransferStats;
+    fanOutResult?: FanOutResult; // 扇出父任务
+}
+
+export interface JobSummary {
+    id: string;
+    parentId?: string;
+    task?: string;
+    schedule?: string;
+    pipeline?: string;
+    fanOut?: string;
+    status: JobStatus;
+    createdAt: string;
+    startedAt: string;
+    endedAt: string;
+    plan: TransferPlan;
+    priority: number;
+    progress: Progress;
+    attempts: number;
+    logTotal: number;
+    stats?: TransferStats;
+    logLines?: string[]; // 最后 tail 行
+    fanOutResult?: FanOutResult;
+}
+
+export interface JobPage {
+    jobs: JobSummary[];
+    nextCursor?: string;
+}
+
+export interface JobQuery {
+    status?: string; // 逗号分隔
+    host?: string;
+    task?: string;
+    schedule?: string;
+    pipeline?: string;
+    fanOut?: string;
+    since?: string;
+    until?: string;
+    q?: string;
+    order?: "asc" | "desc";
+    limit?: number;
+    cursor?: string;
+    tail?: number;
+}
+
+export interface Progress {
+    bytesTotal: number;
+    bytesDone: number;
+    filesTotal: number;
+    filesDone: number;
+    currentFile?: string;
+    rate: number;       // bytes/s
+    etaSeconds: number; // -1 = 未知
+    updatedAt: string;
+}
+
+// 传输结束时的统计；拿不到的字段为 0
+export interface TransferStats {
+    source: "rsync-cli" | "go-rsync" | "scp" | "tar" | "relay-stream";
+    filesTotal: number;
+    filesTransferred: number;
+    filesCreated: number;
+    filesDeleted: number;
+    totalFileSize: number;
+    transferredFileSize: number;
+    literalData: number;
+    matchedData: number;
+    bytesSent: number;
+    bytesReceived: number;
+    speedup: number;
+}
+
+export interface JobAttempt {
+    attempt: number;
+    startedAt: string;
+    endedAt: string;
+    error?: string;
+    transient?: boolean;
+}
+
+export interface HookCommand {
+    host: "source" | "dest" | "exec" | "local";
+    command: string;
+    when?: "success" | "always"; // 只对 postCommand 有效
+    timeoutSeconds?: number;
+}
+
+export interface NotifyConfig {
+    on?: JobStatus[];
+    logLines?: number;
+    webhooks?: { url: string; headers?: Record<string, string>; timeoutSeconds?: number }[];
+    email?: {
+        smtpHost: string;
+        smtpPort?: number;
+        username?: string;
+        password?: string;
+        from: string;
+        to: string[];
+    };
+}
+
+export interface TaskDef extends TransferRequest {
+    name: string;
+    description: string;
+    notify?: NotifyConfig;
+}
+
+export interface Schedule {
+    name: string;
+    task: string;
+    cron: string;
+    timezone: string;
+    enabled: boolean;
+    skipIfRunning: boolean;
+}
+
+export interface ScheduleStatus extends Schedule {
+    nextRun?: string;
+    lastRunAt?: string;
+    lastJobId?: string;
+    lastError?: string;
+}
+
+export interface StepCommand {
+    host: string;
+    command: string;
+    timeoutSeconds?: number;
+}
+
+export interface PipelineStep {
+    name: string;
+    transfer?: TransferRequest;
+    task?: string;
+    command?: StepCommand;
+    needs?: string[];
+    when?: "on_success" | "on_failure" | "always";
+}
+
+export interface PipelineDef {
+    name: string;
+    steps: PipelineStep[];
+}
+
+export interface PipelineStepRun extends PipelineStep {
+    status: "pending" | "running" | "success" | "failed" | "skipped" | "cancelled" | "interrupted";
+    jobId?: string;
+    startedAt: string;
+    endedAt: string;
+    error?: string;
+    output?: string[];
+}
+
+export interface Pipeline {
+    id: string;
+    name: string;
+    status: JobStatus;
+    createdAt: string;
+    startedAt: string;
+    endedAt: string;
+    steps: PipelineStepRun[];
+}
+
+export interface FanOutSpec {
+    destinations: Endpoint[];
+    parallelism?: number; // 0 = 全部同时
+}
+
+export interface FanOutTarget {
+    dest: Endpoint;
+    status: JobStatus;
+    jobId?: string;
+    error?: string;
+}
+
+export interface FanOutResult {
+    targets: FanOutTarget[];
+    succeeded: number;
+    failed: number;
+    cancelled: number;
 }
 
 export interface CreateTransferResponse {
     jobId: string;
+    parentId?: string;
     plan: TransferPlan;
-    precheck: PrecheckResult;
+    precheck: PrecheckResult | null; // 扇出时为 null：每个目标在子任务里各自预检查
 }
 
 export interface PreviewResponse {
     command: string;
+    reason?: string; // execSide=auto 选执行端的理由
 }
 
 
@@ -101,3 +344,10 @@ export interface FSListResult {
     // 预取：1 级子目录内容（key=子目录名）
     children?: Record<string, FSEntry[]>;
 }
+
+export interface JobLogPage {
+    offset: number;
+    lines: string[];
+    nextOffset: number;
+    total: number;
+}